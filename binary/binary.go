@@ -0,0 +1,161 @@
+// Package binary provides context-aware versions of the functions in encoding/binary.
+//
+// The functions read with ctxio.ReadFull, so a read interrupted by the context
+// returns the error of the context, and a stream that ends in the middle of a value
+// returns io.ErrUnexpectedEOF, in the same way as ctxio.ReadFull.
+package binary
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/shogo82148/ctxio"
+)
+
+var errOverflow = errors.New("binary: varint overflows a 64-bit integer")
+
+// Read reads structured binary data from r into data.
+// Data must be a pointer to a fixed-size value or a slice
+// of fixed-size values.
+// See binary.Read for the details.
+//
+// The error is io.EOF only if no bytes were read.
+// If an EOF happens after reading some but not all the bytes,
+// Read returns io.ErrUnexpectedEOF.
+func Read(ctx context.Context, r ctxio.Reader, order binary.ByteOrder, data any) error {
+	size := binary.Size(data)
+	if size < 0 {
+		return errors.New("binary.Read: invalid type " + reflect.TypeOf(data).String())
+	}
+	buf := make([]byte, size)
+	if _, err := ctxio.ReadFull(ctx, r, buf); err != nil {
+		return err
+	}
+	return binary.Read(bytes.NewReader(buf), order, data)
+}
+
+// Write writes the binary representation of data into w.
+// Data must be a fixed-size value or a slice of fixed-size
+// values, or a pointer to such data.
+// See binary.Write for the details.
+func Write(ctx context.Context, w ctxio.Writer, order binary.ByteOrder, data any) error {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, order, data); err != nil {
+		return err
+	}
+	_, err := w.WriteContext(ctx, buf.Bytes())
+	return err
+}
+
+// ReadUvarint reads an encoded unsigned integer from r and returns it as a uint64.
+// The error is io.EOF only if no bytes were read.
+// If an EOF happens after reading some but not all the bytes,
+// ReadUvarint returns io.ErrUnexpectedEOF.
+//
+// ReadUvarint reads one byte at a time, so it doesn't read beyond the varint.
+func ReadUvarint(ctx context.Context, r ctxio.Reader) (uint64, error) {
+	var x uint64
+	var s uint
+	var b [1]byte
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		if _, err := ctxio.ReadFull(ctx, r, b[:]); err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return x, err
+		}
+		if b[0] < 0x80 {
+			if i == binary.MaxVarintLen64-1 && b[0] > 1 {
+				return x, errOverflow
+			}
+			return x | uint64(b[0])<<s, nil
+		}
+		x |= uint64(b[0]&0x7f) << s
+		s += 7
+	}
+	return x, errOverflow
+}
+
+// ReadVarint reads an encoded signed integer from r and returns it as an int64.
+// The error is io.EOF only if no bytes were read.
+// If an EOF happens after reading some but not all the bytes,
+// ReadVarint returns io.ErrUnexpectedEOF.
+func ReadVarint(ctx context.Context, r ctxio.Reader) (int64, error) {
+	ux, err := ReadUvarint(ctx, r) // ok to continue in presence of error
+	x := int64(ux >> 1)
+	if ux&1 != 0 {
+		x = ^x
+	}
+	return x, err
+}
+
+// WriteUvarint writes x to w in the varint encoding,
+// and returns the number of bytes written.
+func WriteUvarint(ctx context.Context, w ctxio.Writer, x uint64) (int, error) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return w.WriteContext(ctx, buf[:n])
+}
+
+// WriteVarint writes x to w in the varint encoding,
+// and returns the number of bytes written.
+func WriteVarint(ctx context.Context, w ctxio.Writer, x int64) (int, error) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], x)
+	return w.WriteContext(ctx, buf[:n])
+}
+
+// ReadUint16 reads a uint16 in the byte order from r.
+func ReadUint16(ctx context.Context, r ctxio.Reader, order binary.ByteOrder) (uint16, error) {
+	var buf [2]byte
+	if _, err := ctxio.ReadFull(ctx, r, buf[:]); err != nil {
+		return 0, err
+	}
+	return order.Uint16(buf[:]), nil
+}
+
+// ReadUint32 reads a uint32 in the byte order from r.
+func ReadUint32(ctx context.Context, r ctxio.Reader, order binary.ByteOrder) (uint32, error) {
+	var buf [4]byte
+	if _, err := ctxio.ReadFull(ctx, r, buf[:]); err != nil {
+		return 0, err
+	}
+	return order.Uint32(buf[:]), nil
+}
+
+// ReadUint64 reads a uint64 in the byte order from r.
+func ReadUint64(ctx context.Context, r ctxio.Reader, order binary.ByteOrder) (uint64, error) {
+	var buf [8]byte
+	if _, err := ctxio.ReadFull(ctx, r, buf[:]); err != nil {
+		return 0, err
+	}
+	return order.Uint64(buf[:]), nil
+}
+
+// WriteUint16 writes v in the byte order to w.
+func WriteUint16(ctx context.Context, w ctxio.Writer, order binary.ByteOrder, v uint16) error {
+	var buf [2]byte
+	order.PutUint16(buf[:], v)
+	_, err := w.WriteContext(ctx, buf[:])
+	return err
+}
+
+// WriteUint32 writes v in the byte order to w.
+func WriteUint32(ctx context.Context, w ctxio.Writer, order binary.ByteOrder, v uint32) error {
+	var buf [4]byte
+	order.PutUint32(buf[:], v)
+	_, err := w.WriteContext(ctx, buf[:])
+	return err
+}
+
+// WriteUint64 writes v in the byte order to w.
+func WriteUint64(ctx context.Context, w ctxio.Writer, order binary.ByteOrder, v uint64) error {
+	var buf [8]byte
+	order.PutUint64(buf[:], v)
+	_, err := w.WriteContext(ctx, buf[:])
+	return err
+}
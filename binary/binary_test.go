@@ -0,0 +1,188 @@
+package binary
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+)
+
+type header struct {
+	Magic   [4]byte
+	Version uint16
+	Flags   uint16
+	Length  int64
+}
+
+func TestReadWrite(t *testing.T) {
+	ctx := context.Background()
+	want := header{Magic: [4]byte{'C', 'T', 'X', 'I'}, Version: 1, Flags: 0x8001, Length: -42}
+
+	var buf bytes.Buffer
+	if err := Write(ctx, ctxio.NewWriter(&buf), binary.BigEndian, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	// compare with encoding/binary.
+	var std bytes.Buffer
+	if err := binary.Write(&std, binary.BigEndian, &want); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), std.Bytes()) {
+		t.Errorf("want %x, got %x", std.Bytes(), buf.Bytes())
+	}
+
+	var got header
+	if err := Read(ctx, ctxio.NewReader(&buf), binary.BigEndian, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestRead_UnexpectedEOF(t *testing.T) {
+	ctx := context.Background()
+	var got header
+	err := Read(ctx, ctxio.NewReader(bytes.NewReader([]byte{1, 2, 3})), binary.BigEndian, &got)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("want io.ErrUnexpectedEOF, got %v", err)
+	}
+	err = Read(ctx, ctxio.NewReader(bytes.NewReader(nil)), binary.BigEndian, &got)
+	if err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+}
+
+func TestRead_Cancel(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+	go func() {
+		pw.WriteContext(context.Background(), []byte{1, 2, 3})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var got header
+	if err := Read(ctx, pr, binary.BigEndian, &got); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestVarint(t *testing.T) {
+	ctx := context.Background()
+	values := []int64{0, 1, -1, 63, -64, 64, math.MaxInt64, math.MinInt64}
+	for _, v := range values {
+		var buf bytes.Buffer
+		n, err := WriteVarint(ctx, ctxio.NewWriter(&buf), v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var std [binary.MaxVarintLen64]byte
+		m := binary.PutVarint(std[:], v)
+		if !bytes.Equal(buf.Bytes(), std[:m]) || n != m {
+			t.Errorf("%d: want %x, got %x", v, std[:m], buf.Bytes())
+		}
+
+		got, err := ReadVarint(ctx, ctxio.NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("want %d, got %d", v, got)
+		}
+	}
+}
+
+func TestUvarint(t *testing.T) {
+	ctx := context.Background()
+	values := []uint64{0, 1, 127, 128, 300, math.MaxUint32, math.MaxUint64}
+	for _, v := range values {
+		var buf bytes.Buffer
+		if _, err := WriteUvarint(ctx, ctxio.NewWriter(&buf), v); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteString("rest")
+		r := ctxio.NewReader(&buf)
+		got, err := ReadUvarint(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("want %d, got %d", v, got)
+		}
+
+		// ReadUvarint must not consume the data after the varint.
+		rest, err := ctxio.ReadAll(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(rest) != "rest" {
+			t.Errorf("want %q, got %q", "rest", rest)
+		}
+	}
+}
+
+func TestReadUvarint_Errors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		in  []byte
+		err error
+	}{
+		{nil, io.EOF},
+		{[]byte{0x80}, io.ErrUnexpectedEOF},
+		{[]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x02}, errOverflow},
+		{bytes.Repeat([]byte{0x80}, 11), errOverflow},
+	}
+	for _, tt := range tests {
+		_, err := ReadUvarint(ctx, ctxio.NewReader(bytes.NewReader(tt.in)))
+		_, stdErr := binary.ReadUvarint(bytes.NewReader(tt.in))
+		if err != tt.err {
+			t.Errorf("%x: want %v, got %v", tt.in, tt.err, err)
+		}
+		if err.Error() != stdErr.Error() {
+			t.Errorf("%x: the error differs from encoding/binary: %v, %v", tt.in, err, stdErr)
+		}
+	}
+}
+
+func TestUint(t *testing.T) {
+	ctx := context.Background()
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		var buf bytes.Buffer
+		w := ctxio.NewWriter(&buf)
+		if err := WriteUint16(ctx, w, order, 0x0102); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteUint32(ctx, w, order, 0x01020304); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteUint64(ctx, w, order, 0x0102030405060708); err != nil {
+			t.Fatal(err)
+		}
+
+		r := ctxio.NewReader(&buf)
+		v16, err := ReadUint16(ctx, r, order)
+		if err != nil || v16 != 0x0102 {
+			t.Errorf("%v: ReadUint16 = %x, %v", order, v16, err)
+		}
+		v32, err := ReadUint32(ctx, r, order)
+		if err != nil || v32 != 0x01020304 {
+			t.Errorf("%v: ReadUint32 = %x, %v", order, v32, err)
+		}
+		v64, err := ReadUint64(ctx, r, order)
+		if err != nil || v64 != 0x0102030405060708 {
+			t.Errorf("%v: ReadUint64 = %x, %v", order, v64, err)
+		}
+		if _, err := ReadUint16(ctx, r, order); err != io.EOF {
+			t.Errorf("want io.EOF, got %v", err)
+		}
+	}
+}
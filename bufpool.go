@@ -0,0 +1,77 @@
+package ctxio
+
+import (
+	"context"
+	"sync"
+)
+
+// BufferPool is a pool of temporary buffers.
+// Copy, ReadAll, Discard and the adapters returned by NewReader and NewWriter
+// get their buffers from the pool carried by the context; see WithBufferPool.
+//
+// A BufferPool must be safe for concurrent use.
+type BufferPool interface {
+	// Get returns a buffer of length n. Its contents are undefined.
+	Get(n int) []byte
+
+	// Put returns a buffer to the pool.
+	// The buffer may have been obtained from another pool.
+	Put(b []byte)
+}
+
+// DefaultBufferPool is the BufferPool used if the context doesn't carry one.
+// It keeps buffers whose capacity is a power of two from 64 bytes to 1MB,
+// in a sync.Pool per size class.
+var DefaultBufferPool BufferPool = &sizeClassPool{}
+
+type bufferPoolKey struct{}
+
+// WithBufferPool returns a copy of ctx that carries p.
+func WithBufferPool(ctx context.Context, p BufferPool) context.Context {
+	return context.WithValue(ctx, bufferPoolKey{}, p)
+}
+
+// BufferPoolFromContext returns the BufferPool carried by ctx,
+// or DefaultBufferPool if ctx doesn't carry one.
+func BufferPoolFromContext(ctx context.Context) BufferPool {
+	if p, ok := ctx.Value(bufferPoolKey{}).(BufferPool); ok && p != nil {
+		return p
+	}
+	return DefaultBufferPool
+}
+
+// maxPooledSize is the maximum size of buffers kept in sizeClassPool.
+const maxPooledSize = 1 << 20
+
+// sizeClassPool is the implementation of DefaultBufferPool.
+type sizeClassPool struct {
+	pools [15]sync.Pool // 64 bytes to 1MB
+}
+
+func sizeClass(n int) int {
+	i := 0
+	for size := 64; size < n; size <<= 1 {
+		i++
+	}
+	return i
+}
+
+func (p *sizeClassPool) Get(n int) []byte {
+	if n > maxPooledSize {
+		return make([]byte, n)
+	}
+	i := sizeClass(n)
+	if b, ok := p.pools[i].Get().(*[]byte); ok {
+		return (*b)[:n]
+	}
+	return make([]byte, n, 64<<i)
+}
+
+func (p *sizeClassPool) Put(b []byte) {
+	c := cap(b)
+	if c < 64 || c > maxPooledSize || c&(c-1) != 0 {
+		return
+	}
+	b = b[:0]
+	p.pools[sizeClass(c)].Put(&b)
+}
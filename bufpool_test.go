@@ -0,0 +1,199 @@
+package ctxio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSizeClassPool(t *testing.T) {
+	p := &sizeClassPool{}
+	for _, tt := range []struct {
+		n       int
+		wantCap int
+	}{
+		{0, 64},
+		{1, 64},
+		{64, 64},
+		{65, 128},
+		{32 * 1024, 32 * 1024},
+		{maxPooledSize, maxPooledSize},
+		{maxPooledSize + 1, maxPooledSize + 1},
+	} {
+		b := p.Get(tt.n)
+		if len(b) != tt.n {
+			t.Errorf("Get(%d): want len %d, got %d", tt.n, tt.n, len(b))
+		}
+		if cap(b) != tt.wantCap {
+			t.Errorf("Get(%d): want cap %d, got %d", tt.n, tt.wantCap, cap(b))
+		}
+		p.Put(b)
+	}
+
+	// the buffers of foreign sizes are dropped.
+	p.Put(make([]byte, 100))
+	p.Put(make([]byte, 10))
+}
+
+// countingPool is a BufferPool that counts the buffers in use.
+type countingPool struct {
+	mu    sync.Mutex
+	gets  int
+	inUse int
+}
+
+func (p *countingPool) Get(n int) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gets++
+	p.inUse++
+	return make([]byte, n)
+}
+
+func (p *countingPool) Put(b []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inUse--
+}
+
+func (p *countingPool) stats() (gets, inUse int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.gets, p.inUse
+}
+
+func TestBufferPoolFromContext(t *testing.T) {
+	if p := BufferPoolFromContext(context.Background()); p != DefaultBufferPool {
+		t.Errorf("want DefaultBufferPool, got %v", p)
+	}
+	pool := &countingPool{}
+	ctx := WithBufferPool(context.Background(), pool)
+	if p := BufferPoolFromContext(ctx); p != pool {
+		t.Errorf("want the pool of the context, got %v", p)
+	}
+}
+
+func TestBufferPool_Copy(t *testing.T) {
+	pool := &countingPool{}
+	ctx := WithBufferPool(context.Background(), pool)
+	content := strings.Repeat("0123456789", 10000)
+
+	// hide ReaderFrom and WriterTo, so Copy needs a buffer.
+	var buf Buffer
+	if _, err := Copy(ctx, writerOnly{&buf}, readerOnly{NewReader(strings.NewReader(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != content {
+		t.Error("content mismatch")
+	}
+	if gets, inUse := pool.stats(); gets == 0 || inUse != 0 {
+		t.Errorf("want buffers returned to the pool, got %d gets and %d in use", gets, inUse)
+	}
+}
+
+func TestBufferPool_ReadAll(t *testing.T) {
+	pool := &countingPool{}
+	ctx := WithBufferPool(context.Background(), pool)
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+
+	got, err := ReadAll(ctx, NewReader(bytes.NewReader(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("content mismatch")
+	}
+	// only the returned slice is in use.
+	if gets, inUse := pool.stats(); gets < 2 || inUse != 1 {
+		t.Errorf("want outgrown buffers returned to the pool, got %d gets and %d in use", gets, inUse)
+	}
+}
+
+func TestBufferPool_Adapters(t *testing.T) {
+	pool := &countingPool{}
+	ctx := WithBufferPool(context.Background(), pool)
+	content := strings.Repeat("0123456789", 10000)
+
+	r := newGoReader(strings.NewReader(content))
+	var sb strings.Builder
+	w := newGoWriter(&sb)
+	defer r.Close()
+	defer w.Close()
+
+	buf := make([]byte, 1000)
+	for {
+		n, err := r.ReadContext(ctx, buf)
+		if _, err := w.WriteContext(ctx, buf[:n]); err != nil {
+			t.Fatal(err)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if sb.String() != content {
+		t.Error("content mismatch")
+	}
+	if gets, inUse := pool.stats(); gets == 0 || inUse != 0 {
+		t.Errorf("want buffers returned to the pool, got %d gets and %d in use", gets, inUse)
+	}
+}
+
+// the benchmarks below report the memory a server keeps per idle connection
+// and per copy.
+
+func BenchmarkNewReader_Idle(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := newGoReader(strings.NewReader(""))
+		r.Close()
+	}
+}
+
+func BenchmarkNewWriter_Idle(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w := newGoWriter(io.Discard)
+		w.Close()
+	}
+}
+
+func BenchmarkCopy_Pooled(b *testing.B) {
+	ctx := context.Background()
+	content := strings.Repeat("x", 64*1024)
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		src := readerOnly{NewReader(strings.NewReader(content))}
+		if _, err := Copy(ctx, writerOnly{Discard}, src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAdapters_Parallel(b *testing.B) {
+	ctx := context.Background()
+	content := strings.Repeat("x", 4*1024)
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		buf := make([]byte, 1024)
+		for pb.Next() {
+			r := newGoReader(strings.NewReader(content))
+			w := newGoWriter(io.Discard)
+			for {
+				n, err := r.ReadContext(ctx, buf)
+				if _, err := w.WriteContext(ctx, buf[:n]); err != nil {
+					b.Fatal(err)
+				}
+				if err != nil {
+					break
+				}
+			}
+			r.Close()
+			w.Close()
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package chunked
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+func TestChunk(t *testing.T) {
+	ctx := context.Background()
+	var b bytes.Buffer
+
+	w := NewWriter(ctxio.NewWriter(&b))
+	const chunk1 = "hello, "
+	const chunk2 = "world! 0123456789abcdef"
+	w.WriteContext(ctx, []byte(chunk1))
+	w.WriteContext(ctx, []byte(chunk2))
+	w.Close()
+
+	if g, e := b.String(), "7\r\nhello, \r\n17\r\nworld! 0123456789abcdef\r\n0\r\n\r\n"; g != e {
+		t.Fatalf("chunk writer wrote %q; want %q", g, e)
+	}
+
+	r := NewReader(ctxio.NewReader(&b))
+	data, err := ctxio.ReadAll(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, e := string(data), chunk1+chunk2; g != e {
+		t.Errorf("chunk reader read %q; want %q", g, e)
+	}
+	if len(r.Trailer()) != 0 {
+		t.Errorf("want no trailers, got %v", r.Trailer())
+	}
+}
+
+func TestReader_Conformance(t *testing.T) {
+	in := "7\r\nhello, \r\n17\r\nworld! 0123456789abcdef\r\n0\r\n\r\n"
+	r := NewReader(ctxio.NewReader(strings.NewReader(in)))
+	if err := ctxiotest.TestReader(r, []byte("hello, world! 0123456789abcdef")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTrailer(t *testing.T) {
+	ctx := context.Background()
+	var b bytes.Buffer
+
+	w := NewWriter(ctxio.NewWriter(&b))
+	w.Trailer = http.Header{"Checksum": {"abc"}, "X-Foo": {"1", "2"}}
+	w.WriteContext(ctx, []byte("hello"))
+	if err := w.CloseContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteContext(ctx, []byte("after close")); err == nil {
+		t.Error("want error, got nil")
+	}
+
+	r := NewReader(ctxio.NewReader(&b))
+	data, err := ctxio.ReadAll(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("want %q, got %q", "hello", data)
+	}
+	if got := r.Trailer().Get("Checksum"); got != "abc" {
+		t.Errorf("want %q, got %q", "abc", got)
+	}
+	if got := r.Trailer().Values("X-Foo"); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("want [1 2], got %v", got)
+	}
+}
+
+func TestChunkExtension(t *testing.T) {
+	in := "5;name=value\r\nhello\r\n1;quoted=\"a;b\"\r\n!\r\n0;last\r\nfoo: bar\r\n\r\n"
+	r := NewReader(ctxio.NewReader(strings.NewReader(in)))
+	data, err := ctxio.ReadAll(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello!" {
+		t.Errorf("want %q, got %q", "hello!", data)
+	}
+	if got := r.Trailer().Get("Foo"); got != "bar" {
+		t.Errorf("want %q, got %q", "bar", got)
+	}
+}
+
+func TestMalformedChunks(t *testing.T) {
+	tests := []string{
+		"",                          // no terminating chunk
+		"5\r\nhello",                // unterminated chunk
+		"5\nhello\r\n0\r\n\r\n",     // bare LF
+		"5\r\nhelloXX0\r\n\r\n",     // bad chunk footer
+		"z\r\nhello\r\n0\r\n\r\n",   // invalid hex
+		"0\r\nfoo bar\r\n\r\n",      // malformed trailer
+		"0\r\nfoo: bar\r\n baz\r\n", // obsolete line folding
+		"0\r\n",                     // no trailer section
+	}
+	for _, in := range tests {
+		r := NewReader(ctxio.NewReader(strings.NewReader(in)))
+		if _, err := ctxio.ReadAll(context.Background(), r); err == nil {
+			t.Errorf("%q: want error, got nil", in)
+		}
+	}
+}
+
+func TestReader_Cancel(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+	go pw.WriteContext(context.Background(), []byte("5\r\nhello\r\n"))
+
+	r := NewReader(pr)
+	buf := make([]byte, 64)
+	n, err := r.ReadContext(context.Background(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Errorf("want %q, got %q", "hello", buf[:n])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.ReadContext(ctx, buf); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// FuzzReader checks that Reader doesn't accept any input
+// that net/http/httputil decodes differently.
+func FuzzReader(f *testing.F) {
+	f.Add([]byte("7\r\nhello, \r\n17\r\nworld! 0123456789abcdef\r\n0\r\n\r\n"))
+	f.Add([]byte("5;ext\r\nhello\r\n0\r\nfoo: bar\r\n\r\n"))
+	f.Add([]byte("0\r\n\r\n"))
+	f.Add([]byte("5\r\nhello"))
+	f.Fuzz(func(t *testing.T, in []byte) {
+		r := NewReader(ctxio.NewReader(bytes.NewReader(in)))
+		got, err := ctxio.ReadAll(context.Background(), r)
+		if err != nil {
+			return
+		}
+		want, err := io.ReadAll(httputil.NewChunkedReader(bytes.NewReader(in)))
+		if err != nil {
+			t.Fatalf("httputil failed to decode %q: %v", in, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("decoded %q, httputil decoded %q", got, want)
+		}
+	})
+}
+
+// FuzzWriter checks that Writer and httputil's chunked writer
+// produce the bodies that the other side's reader decodes.
+func FuzzWriter(f *testing.F) {
+	f.Add([]byte("hello, world"), uint8(5))
+	f.Add([]byte(""), uint8(1))
+	f.Add(bytes.Repeat([]byte("x"), 1000), uint8(255))
+	f.Fuzz(func(t *testing.T, data []byte, size uint8) {
+		ctx := context.Background()
+		chunk := int(size) + 1
+
+		// Writer -> httputil
+		var b bytes.Buffer
+		w := NewWriter(ctxio.NewWriter(&b))
+		for p := data; len(p) > 0; {
+			n := chunk
+			if n > len(p) {
+				n = len(p)
+			}
+			if _, err := w.WriteContext(ctx, p[:n]); err != nil {
+				t.Fatal(err)
+			}
+			p = p[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(httputil.NewChunkedReader(bytes.NewReader(b.Bytes())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("httputil decoded %q, want %q", got, data)
+		}
+
+		// httputil -> Reader
+		b.Reset()
+		cw := httputil.NewChunkedWriter(&b)
+		for p := data; len(p) > 0; {
+			n := chunk
+			if n > len(p) {
+				n = len(p)
+			}
+			if _, err := cw.Write(p[:n]); err != nil {
+				t.Fatal(err)
+			}
+			p = p[n:]
+		}
+		cw.Close()
+		b.WriteString("\r\n") // httputil doesn't write the end of the trailer section.
+		got, err = ctxio.ReadAll(ctx, NewReader(ctxio.NewReader(&b)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("decoded %q, want %q", got, data)
+		}
+	})
+}
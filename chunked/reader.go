@@ -0,0 +1,268 @@
+// Package chunked implements the HTTP/1.1 chunked transfer coding over ctxio streams.
+package chunked
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/textproto"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/internal/bridge"
+)
+
+const maxLineLength = 4096 // assumed <= bufio.defaultBufSize
+
+// maxTrailerSize is the maximum total size of the trailer section.
+const maxTrailerSize = 64 << 10
+
+// ErrLineTooLong is returned when reading a chunk header or a trailer line that is too long.
+var ErrLineTooLong = errors.New("chunked: header line too long")
+
+// Reader decodes a body in the chunked transfer coding.
+//
+// Chunk extensions are ignored.
+// The trailer section after the last chunk is parsed, and available from Trailer.
+//
+// The Reader keeps the first error it encounters, including context errors.
+type Reader struct {
+	br       bridge.Reader
+	r        *bufio.Reader
+	n        uint64 // unread bytes in chunk
+	err      error
+	buf      [2]byte
+	checkEnd bool  // whether need to check for \r\n chunk footer
+	excess   int64 // "excessive" chunk overhead, for malicious sender detection
+	trailer  http.Header
+}
+
+// NewReader returns a new Reader that decodes the chunked body read from r.
+// The Reader returns io.EOF when the final 0-length chunk and the trailer section are read.
+// It buffers the input, and may read more data than the body from r.
+func NewReader(r ctxio.Reader) *Reader {
+	cr := &Reader{}
+	cr.br.R = r
+	cr.r = bufio.NewReader(&cr.br)
+	return cr
+}
+
+// Trailer returns the trailer fields of the body.
+// It returns nil until ReadContext returns io.EOF.
+func (cr *Reader) Trailer() http.Header {
+	return cr.trailer
+}
+
+func (cr *Reader) beginChunk() {
+	// chunk-size CRLF
+	var line []byte
+	line, cr.err = readChunkLine(cr.r)
+	if cr.err != nil {
+		return
+	}
+	cr.excess += int64(len(line)) + 2 // header, plus \r\n after the chunk data
+	line = trimTrailingWhitespace(line)
+	line = removeChunkExtension(line)
+	cr.n, cr.err = parseHexUint(line)
+	if cr.err != nil {
+		return
+	}
+	// We accept 16 bytes of overhead per chunk, plus twice the amount of real data in the chunk.
+	// See net/http/internal for the details.
+	cr.excess -= 16 + (2 * int64(cr.n))
+	if cr.excess < 0 {
+		cr.excess = 0
+	}
+	if cr.excess > 16*1024 {
+		cr.err = errors.New("chunked: chunked encoding contains too much non-data")
+		return
+	}
+	if cr.n == 0 {
+		cr.trailer, cr.err = readTrailer(cr.r)
+		if cr.err == nil {
+			cr.err = io.EOF
+		}
+	}
+}
+
+func (cr *Reader) chunkHeaderAvailable() bool {
+	n := cr.r.Buffered()
+	if n > 0 {
+		peek, _ := cr.r.Peek(n)
+		return bytes.IndexByte(peek, '\n') >= 0
+	}
+	return false
+}
+
+// ReadContext implements ctxio.Reader, reading the decoded body.
+func (cr *Reader) ReadContext(ctx context.Context, b []byte) (n int, err error) {
+	cr.br.Ctx = ctx
+	defer func() { cr.br.Ctx = nil }()
+
+	for cr.err == nil {
+		if cr.checkEnd {
+			if n > 0 && cr.r.Buffered() < 2 {
+				// We have some data. Return early (per the io.Reader
+				// contract) instead of potentially blocking while
+				// reading more.
+				break
+			}
+			if _, cr.err = io.ReadFull(cr.r, cr.buf[:2]); cr.err == nil {
+				if string(cr.buf[:]) != "\r\n" {
+					cr.err = errors.New("chunked: malformed chunked encoding")
+					break
+				}
+			} else {
+				if cr.err == io.EOF {
+					cr.err = io.ErrUnexpectedEOF
+				}
+				break
+			}
+			cr.checkEnd = false
+		}
+		if cr.n == 0 {
+			if n > 0 && !cr.chunkHeaderAvailable() {
+				// We've read enough. Don't potentially block
+				// reading a new chunk header.
+				break
+			}
+			cr.beginChunk()
+			continue
+		}
+		if len(b) == 0 {
+			break
+		}
+		rbuf := b
+		if uint64(len(rbuf)) > cr.n {
+			rbuf = rbuf[:cr.n]
+		}
+		var n0 int
+		n0, cr.err = cr.r.Read(rbuf)
+		n += n0
+		b = b[n0:]
+		cr.n -= uint64(n0)
+		// If we're at the end of a chunk, read the next two
+		// bytes to verify they are "\r\n".
+		if cr.n == 0 && cr.err == nil {
+			cr.checkEnd = true
+		} else if cr.err == io.EOF {
+			cr.err = io.ErrUnexpectedEOF
+		}
+	}
+	return n, cr.err
+}
+
+// Read a line of bytes (up to \n) from b.
+// Give up if the line exceeds maxLineLength.
+// The returned bytes are owned by the bufio.Reader
+// so they are only valid until the next bufio read.
+func readChunkLine(b *bufio.Reader) ([]byte, error) {
+	p, err := b.ReadSlice('\n')
+	if err != nil {
+		// We always know when EOF is coming.
+		// If the caller asked for a line, there should be a line.
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		} else if err == bufio.ErrBufferFull {
+			err = ErrLineTooLong
+		}
+		return nil, err
+	}
+
+	// Verify that the line ends in a CRLF, and that no CRs appear before the end.
+	if idx := bytes.IndexByte(p, '\r'); idx == -1 {
+		return nil, errors.New("chunked: chunked line ends with bare LF")
+	} else if idx != len(p)-2 {
+		return nil, errors.New("chunked: invalid CR in chunked line")
+	}
+	p = p[:len(p)-2] // trim CRLF
+
+	if len(p) >= maxLineLength {
+		return nil, ErrLineTooLong
+	}
+	return p, nil
+}
+
+// readTrailer reads the trailer section and the empty line that terminates the body.
+func readTrailer(b *bufio.Reader) (http.Header, error) {
+	trailer := http.Header{}
+	size := 0
+	for {
+		p, err := b.ReadSlice('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			} else if err == bufio.ErrBufferFull {
+				err = ErrLineTooLong
+			}
+			return nil, err
+		}
+		size += len(p)
+		if size > maxTrailerSize {
+			return nil, errors.New("chunked: trailer section too large")
+		}
+		p = bytes.TrimSuffix(p[:len(p)-1], []byte("\r"))
+		if len(p) == 0 {
+			return trailer, nil
+		}
+		if isOWS(p[0]) {
+			return nil, errors.New("chunked: obsolete line folding in trailer")
+		}
+		key, value, ok := bytes.Cut(p, []byte(":"))
+		if !ok || len(key) == 0 || isOWS(key[len(key)-1]) {
+			return nil, errors.New("chunked: malformed trailer line")
+		}
+		value = bytes.TrimLeft(trimTrailingWhitespace(value), " \t")
+		k := textproto.CanonicalMIMEHeaderKey(string(key))
+		trailer[k] = append(trailer[k], string(value))
+	}
+}
+
+func trimTrailingWhitespace(b []byte) []byte {
+	for len(b) > 0 && isOWS(b[len(b)-1]) {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+func isOWS(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+// removeChunkExtension removes any chunk-extension from p.
+// For example,
+//
+//	"0" => "0"
+//	"0;token" => "0"
+//	"0;token=val" => "0"
+//	`0;token="quoted string"` => "0"
+func removeChunkExtension(p []byte) []byte {
+	p, _, _ = bytes.Cut(p, []byte(";"))
+	return p
+}
+
+func parseHexUint(v []byte) (n uint64, err error) {
+	if len(v) == 0 {
+		return 0, errors.New("chunked: empty hex number for chunk length")
+	}
+	for i, b := range v {
+		switch {
+		case '0' <= b && b <= '9':
+			b = b - '0'
+		case 'a' <= b && b <= 'f':
+			b = b - 'a' + 10
+		case 'A' <= b && b <= 'F':
+			b = b - 'A' + 10
+		default:
+			return 0, errors.New("chunked: invalid byte in chunk length")
+		}
+		if i == 16 {
+			return 0, errors.New("chunked: chunk length too large")
+		}
+		n <<= 4
+		n |= uint64(b)
+	}
+	return
+}
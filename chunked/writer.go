@@ -0,0 +1,82 @@
+package chunked
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/shogo82148/ctxio"
+)
+
+var errClosed = errors.New("chunked: write after close")
+
+// Writer encodes a body in the chunked transfer coding.
+// Each call of WriteContext writes a chunk.
+type Writer struct {
+	w      ctxio.Writer
+	closed bool
+
+	// Trailer is the trailer fields written by CloseContext.
+	Trailer http.Header
+}
+
+// NewWriter returns a new Writer that writes the chunked body to w.
+func NewWriter(w ctxio.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteContext writes data as one chunk.
+// Empty data is not written, because a 0-length chunk terminates the body.
+func (cw *Writer) WriteContext(ctx context.Context, data []byte) (n int, err error) {
+	if cw.closed {
+		return 0, errClosed
+	}
+
+	// Don't send 0-length data. It looks like EOF for chunked encoding.
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	var hdr [18]byte
+	header := strconv.AppendInt(hdr[:0], int64(len(data)), 16)
+	header = append(header, '\r', '\n')
+	if _, err = cw.w.WriteContext(ctx, header); err != nil {
+		return 0, err
+	}
+	if n, err = cw.w.WriteContext(ctx, data); err != nil {
+		return
+	}
+	if n != len(data) {
+		err = io.ErrShortWrite
+		return
+	}
+	_, err = cw.w.WriteContext(ctx, []byte("\r\n"))
+	return
+}
+
+// CloseContext writes the final 0-length chunk, the trailer fields and
+// the empty line that terminates the body.
+// It does not close the underlying writer.
+func (cw *Writer) CloseContext(ctx context.Context) error {
+	if cw.closed {
+		return errClosed
+	}
+	cw.closed = true
+
+	var buf bytes.Buffer
+	buf.WriteString("0\r\n")
+	if err := cw.Trailer.Write(&buf); err != nil {
+		return err
+	}
+	buf.WriteString("\r\n")
+	_, err := cw.w.WriteContext(ctx, buf.Bytes())
+	return err
+}
+
+// Close is same as CloseContext with context.Background().
+func (cw *Writer) Close() error {
+	return cw.CloseContext(context.Background())
+}
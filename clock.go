@@ -0,0 +1,84 @@
+package ctxio
+
+import (
+	"context"
+	"time"
+)
+
+// Clock abstracts the time functions used by the time-based features,
+// such as idle timeouts, rate limits and progress reports,
+// so that tests can drive them deterministically.
+//
+// The clock is carried by the context passed to each operation; see WithClock.
+// A Limiter uses the clock given to NewLimiterWithClock instead.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the subset of *time.Timer used by Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// SystemClock is the Clock backed by the time package.
+// It is used if the context doesn't carry a clock.
+var SystemClock Clock = systemClock{}
+
+type clockKey struct{}
+
+// WithClock returns a copy of ctx that carries c.
+// The time-based features use c instead of SystemClock
+// for the operations called with the returned context.
+//
+// If c is not SystemClock, IdleTimeoutReader and IdleTimeoutWriter use
+// timers of c instead of the deadlines of the underlying reader or writer,
+// because the deadlines are measured by the system clock.
+func WithClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, c)
+}
+
+// ClockFromContext returns the clock carried by ctx,
+// or SystemClock if ctx doesn't carry a clock.
+func ClockFromContext(ctx context.Context) Clock {
+	if c, ok := ctx.Value(clockKey{}).(Clock); ok && c != nil {
+		return c
+	}
+	return SystemClock
+}
+
+// isSystemClock reports whether c is the clock backed by the time package.
+// It doesn't compare c with SystemClock, because c may not be comparable.
+func isSystemClock(c Clock) bool {
+	_, ok := c.(systemClock)
+	return ok
+}
+
+// systemClock is the clock backed by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.t.Stop()
+}
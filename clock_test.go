@@ -0,0 +1,24 @@
+package ctxio
+
+import (
+	"testing"
+)
+
+// funcClock is a Clock that is not comparable.
+type funcClock struct {
+	Clock
+	_ func()
+}
+
+func TestIsSystemClock(t *testing.T) {
+	if !isSystemClock(SystemClock) {
+		t.Error("want true for SystemClock")
+	}
+	if isSystemClock(struct{ Clock }{SystemClock}) {
+		t.Error("want false for a wrapped SystemClock")
+	}
+	// must not panic.
+	if isSystemClock(funcClock{Clock: SystemClock}) {
+		t.Error("want false for funcClock")
+	}
+}
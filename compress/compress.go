@@ -0,0 +1,57 @@
+// Package compress provides context-aware readers and writers
+// of the gzip, zlib and flate formats.
+//
+// They wrap the readers and writers of compress/gzip, compress/zlib and compress/flate,
+// and pass the context of each call down to the underlying ctxio.Reader or ctxio.Writer,
+// so that canceling the context interrupts the blocked I/O.
+//
+// The compressed streams keep the first error they encounter.
+// After a call fails because of its context, the reader or writer must be Reset
+// before it is used again.
+package compress
+
+import (
+	"context"
+	"io"
+
+	"github.com/shogo82148/ctxio/internal/bridge"
+)
+
+// reader is the common part of the decompressors.
+type reader struct {
+	br bridge.Reader
+	r  io.ReadCloser
+}
+
+func (r *reader) readContext(ctx context.Context, data []byte) (int, error) {
+	r.br.Ctx = ctx
+	defer func() { r.br.Ctx = nil }()
+	return r.r.Read(data)
+}
+
+// writer is the common part of the compressors.
+type writer struct {
+	bw bridge.Writer
+	w  interface {
+		io.WriteCloser
+		Flush() error
+	}
+}
+
+func (w *writer) writeContext(ctx context.Context, data []byte) (int, error) {
+	w.bw.Ctx = ctx
+	defer func() { w.bw.Ctx = nil }()
+	return w.w.Write(data)
+}
+
+func (w *writer) flushContext(ctx context.Context) error {
+	w.bw.Ctx = ctx
+	defer func() { w.bw.Ctx = nil }()
+	return w.w.Flush()
+}
+
+func (w *writer) closeContext(ctx context.Context) error {
+	w.bw.Ctx = ctx
+	defer func() { w.bw.Ctx = nil }()
+	return w.w.Close()
+}
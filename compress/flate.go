@@ -0,0 +1,96 @@
+package compress
+
+import (
+	"compress/flate"
+	"context"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// FlateReader is a ctxio.Reader that decompresses the raw DEFLATE format.
+type FlateReader struct {
+	reader
+}
+
+// NewFlateReader returns a new FlateReader that decompresses r.
+func NewFlateReader(r ctxio.Reader) *FlateReader {
+	return NewFlateReaderDict(r, nil)
+}
+
+// NewFlateReaderDict is like NewFlateReader but initializes the reader with a preset dictionary.
+func NewFlateReaderDict(r ctxio.Reader, dict []byte) *FlateReader {
+	z := &FlateReader{}
+	z.br.R = r
+	z.r = flate.NewReaderDict(&z.br, dict)
+	return z
+}
+
+// ReadContext implements ctxio.Reader, reading uncompressed bytes from its underlying Reader.
+func (z *FlateReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return z.readContext(ctx, data)
+}
+
+// Reset discards any buffered data and resets the FlateReader as if it was
+// newly initialized with the given reader.
+func (z *FlateReader) Reset(r ctxio.Reader, dict []byte) error {
+	z.br.R = r
+	return z.r.(flate.Resetter).Reset(&z.br, dict)
+}
+
+// Close closes the FlateReader. It does not close the underlying reader.
+func (z *FlateReader) Close() error {
+	return z.r.Close()
+}
+
+// FlateWriter is a ctxio.Writer that compresses data to the raw DEFLATE format.
+type FlateWriter struct {
+	writer
+	zw *flate.Writer
+}
+
+// NewFlateWriter returns a new FlateWriter compressing data at the given level.
+// See flate.NewWriter for the compression levels.
+func NewFlateWriter(w ctxio.Writer, level int) (*FlateWriter, error) {
+	return NewFlateWriterDict(w, level, nil)
+}
+
+// NewFlateWriterDict is like NewFlateWriter but initializes the new FlateWriter with a preset dictionary.
+func NewFlateWriterDict(w ctxio.Writer, level int, dict []byte) (*FlateWriter, error) {
+	z := &FlateWriter{}
+	z.bw.W = w
+	zw, err := flate.NewWriterDict(&z.bw, level, dict)
+	if err != nil {
+		return nil, err
+	}
+	z.zw = zw
+	z.w = zw
+	return z, nil
+}
+
+// WriteContext implements ctxio.Writer, writing a compressed form of data to the underlying Writer.
+func (z *FlateWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	return z.writeContext(ctx, data)
+}
+
+// FlushContext flushes any pending compressed data to the underlying writer.
+func (z *FlateWriter) FlushContext(ctx context.Context) error {
+	return z.flushContext(ctx)
+}
+
+// CloseContext flushes and closes the FlateWriter. It does not close the underlying writer.
+func (z *FlateWriter) CloseContext(ctx context.Context) error {
+	return z.closeContext(ctx)
+}
+
+// Close is same as CloseContext with context.Background().
+func (z *FlateWriter) Close() error {
+	return z.CloseContext(context.Background())
+}
+
+// Reset discards the FlateWriter's state and makes it equivalent to the
+// result of NewFlateWriter or NewFlateWriterDict called with w
+// and the original level and dictionary.
+func (z *FlateWriter) Reset(w ctxio.Writer) {
+	z.bw.W = w
+	z.zw.Reset(&z.bw)
+}
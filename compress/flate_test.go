@@ -0,0 +1,46 @@
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"testing"
+
+	"github.com/shogo82148/ctxio"
+)
+
+func TestFlate(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	zw, err := NewFlateWriter(ctxio.NewWriter(&buf), flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.WriteContext(ctx, []byte("hello, world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.CloseContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	compressed := buf.Bytes()
+
+	zr := NewFlateReader(ctxio.NewReader(bytes.NewReader(compressed)))
+	data, err := ctxio.ReadAll(ctx, zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+
+	if err := zr.Reset(ctxio.NewReader(bytes.NewReader(compressed)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ctxio.ReadAll(ctx, zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+}
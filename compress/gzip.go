@@ -0,0 +1,142 @@
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// GzipReader is a ctxio.Reader that decompresses the gzip format.
+type GzipReader struct {
+	reader
+	buf *bufio.Reader
+	zr  *gzip.Reader
+}
+
+// NewGzipReader creates a new GzipReader reading the given reader.
+// It reads the gzip header with ctx.
+//
+// The GzipReader buffers the input, and may read more data than necessary from r.
+// To read the members of a multistream file one by one,
+// disable Multistream and call NextMember after each member.
+func NewGzipReader(ctx context.Context, r ctxio.Reader) (*GzipReader, error) {
+	z := &GzipReader{}
+	z.br.R = r
+	z.br.Ctx = ctx
+	defer func() { z.br.Ctx = nil }()
+
+	z.buf = bufio.NewReader(&z.br)
+	zr, err := gzip.NewReader(z.buf)
+	if err != nil {
+		return nil, err
+	}
+	z.zr = zr
+	z.r = zr
+	return z, nil
+}
+
+// ReadContext implements ctxio.Reader, reading uncompressed bytes from its underlying Reader.
+func (z *GzipReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return z.readContext(ctx, data)
+}
+
+// Header returns the header of the current gzip member.
+func (z *GzipReader) Header() gzip.Header {
+	return z.zr.Header
+}
+
+// Multistream controls whether the reader supports multistream files.
+// See (*gzip.Reader).Multistream.
+func (z *GzipReader) Multistream(ok bool) {
+	z.zr.Multistream(ok)
+}
+
+// Reset discards the GzipReader z's state and makes it equivalent to the
+// result of its original state from NewGzipReader, but reading from r instead.
+// This permits reusing a GzipReader rather than allocating a new one.
+// The data buffered from the previous reader is discarded.
+func (z *GzipReader) Reset(ctx context.Context, r ctxio.Reader) error {
+	z.br.R = r
+	z.buf.Reset(&z.br)
+	return z.NextMember(ctx)
+}
+
+// NextMember reads the header of the next member of a multistream file,
+// keeping the data buffered from the underlying reader.
+// Use it with Multistream(false) to read the members one by one.
+// It returns io.EOF if there are no more members.
+func (z *GzipReader) NextMember(ctx context.Context) error {
+	z.br.Ctx = ctx
+	defer func() { z.br.Ctx = nil }()
+	return z.zr.Reset(z.buf)
+}
+
+// Close closes the GzipReader. It does not close the underlying reader.
+func (z *GzipReader) Close() error {
+	return z.zr.Close()
+}
+
+// GzipWriter is a ctxio.Writer that compresses data to the gzip format.
+type GzipWriter struct {
+	writer
+	zw *gzip.Writer
+}
+
+// NewGzipWriter returns a new GzipWriter.
+// Writes to the returned writer are compressed and written to w.
+func NewGzipWriter(w ctxio.Writer) *GzipWriter {
+	z, _ := NewGzipWriterLevel(w, gzip.DefaultCompression)
+	return z
+}
+
+// NewGzipWriterLevel is like NewGzipWriter but specifies the compression level.
+func NewGzipWriterLevel(w ctxio.Writer, level int) (*GzipWriter, error) {
+	z := &GzipWriter{}
+	z.bw.W = w
+	zw, err := gzip.NewWriterLevel(&z.bw, level)
+	if err != nil {
+		return nil, err
+	}
+	z.zw = zw
+	z.w = zw
+	return z, nil
+}
+
+// Header returns the gzip header written by the first call of
+// WriteContext, FlushContext or CloseContext.
+// The caller may modify it before that.
+func (z *GzipWriter) Header() *gzip.Header {
+	return &z.zw.Header
+}
+
+// WriteContext implements ctxio.Writer, writing a compressed form of data to the underlying Writer.
+func (z *GzipWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	return z.writeContext(ctx, data)
+}
+
+// FlushContext flushes any pending compressed data to the underlying writer.
+func (z *GzipWriter) FlushContext(ctx context.Context) error {
+	return z.flushContext(ctx)
+}
+
+// CloseContext closes the GzipWriter by flushing any unwritten data to the underlying
+// writer and writing the gzip footer. It does not close the underlying writer.
+func (z *GzipWriter) CloseContext(ctx context.Context) error {
+	return z.closeContext(ctx)
+}
+
+// Close is same as CloseContext with context.Background().
+func (z *GzipWriter) Close() error {
+	return z.CloseContext(context.Background())
+}
+
+// Reset discards the GzipWriter z's state and makes it equivalent to the
+// result of its original state from NewGzipWriter or NewGzipWriterLevel, but
+// writing to w instead. This permits reusing a GzipWriter rather than
+// allocating a new one.
+func (z *GzipWriter) Reset(w ctxio.Writer) {
+	z.bw.W = w
+	z.zw.Reset(&z.bw)
+}
@@ -0,0 +1,216 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+func gzipData(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGzip(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	zw := NewGzipWriter(ctxio.NewWriter(&buf))
+	zw.Header().Name = "hello.txt"
+	if _, err := zw.WriteContext(ctx, []byte("hello, world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.CloseContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := NewGzipReader(ctx, ctxio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.Header().Name != "hello.txt" {
+		t.Errorf("want %q, got %q", "hello.txt", zr.Header().Name)
+	}
+	data, err := ctxio.ReadAll(ctx, zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+}
+
+func TestGzipReader_Conformance(t *testing.T) {
+	const s = "hello, world! 0123456789abcdef"
+	r := ctxio.NewReader(bytes.NewReader(gzipData(t, s)))
+	zr, err := NewGzipReader(context.Background(), ctxiotest.OneByteReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctxiotest.TestReader(zr, []byte(s)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGzipReader_Multistream(t *testing.T) {
+	ctx := context.Background()
+	src := append(gzipData(t, "hello, "), gzipData(t, "world")...)
+
+	zr, err := NewGzipReader(ctx, ctxio.NewReader(bytes.NewReader(src)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ctxio.ReadAll(ctx, zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+
+	// read the members one by one.
+	if err := zr.Reset(ctx, ctxio.NewReader(bytes.NewReader(src))); err != nil {
+		t.Fatal(err)
+	}
+	zr.Multistream(false)
+	for i, want := range []string{"hello, ", "world"} {
+		if i > 0 {
+			if err := zr.NextMember(ctx); err != nil {
+				t.Fatal(err)
+			}
+			zr.Multistream(false)
+		}
+		data, err = ctxio.ReadAll(ctx, zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("want %q, got %q", want, data)
+		}
+	}
+	if err := zr.NextMember(ctx); err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+}
+
+// sliceReader is a ctxio.Reader whose dynamic type is not comparable.
+type sliceReader []*bytes.Reader
+
+func (r sliceReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return r[0].Read(data)
+}
+
+func TestGzipReader_Reset(t *testing.T) {
+	ctx := context.Background()
+	r := sliceReader{bytes.NewReader(gzipData(t, "hello"))}
+	zr, err := NewGzipReader(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reset doesn't compare the readers, and always discards the buffered data.
+	for _, s := range []string{"hello", "world"} {
+		r[0] = bytes.NewReader(gzipData(t, s))
+		if err := zr.Reset(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ctxio.ReadAll(ctx, zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != s {
+			t.Errorf("want %q, got %q", s, data)
+		}
+	}
+}
+
+func TestGzipReader_Cancel(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+
+	src := gzipData(t, "hello, world")
+	go func() {
+		// send only the header.
+		pw.WriteContext(context.Background(), src[:10])
+	}()
+
+	zr, err := NewGzipReader(context.Background(), pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = zr.ReadContext(ctx, make([]byte, 64))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestNewGzipReader_Cancel(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := NewGzipReader(ctx, pr)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestGzipWriter_Cancel(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+
+	zw := NewGzipWriter(pw)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// the header is written by the first write.
+	if _, err := zw.WriteContext(ctx, []byte("hello, world")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestGzipWriter_Reset(t *testing.T) {
+	ctx := context.Background()
+	zw := NewGzipWriter(ctxio.Discard)
+	for _, s := range []string{"hello", "world"} {
+		var buf bytes.Buffer
+		zw.Reset(ctxio.NewWriter(&buf))
+		if _, err := zw.WriteContext(ctx, []byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if _, err := out.ReadFrom(zr); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != s {
+			t.Errorf("want %q, got %q", s, out.String())
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package compress
+
+import (
+	"compress/zlib"
+	"context"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// ZlibReader is a ctxio.Reader that decompresses the zlib format.
+type ZlibReader struct {
+	reader
+}
+
+// NewZlibReader creates a new ZlibReader reading the given reader.
+// It reads the zlib header with ctx.
+func NewZlibReader(ctx context.Context, r ctxio.Reader) (*ZlibReader, error) {
+	return NewZlibReaderDict(ctx, r, nil)
+}
+
+// NewZlibReaderDict is like NewZlibReader but uses a preset dictionary.
+func NewZlibReaderDict(ctx context.Context, r ctxio.Reader, dict []byte) (*ZlibReader, error) {
+	z := &ZlibReader{}
+	z.br.R = r
+	z.br.Ctx = ctx
+	defer func() { z.br.Ctx = nil }()
+
+	zr, err := zlib.NewReaderDict(&z.br, dict)
+	if err != nil {
+		return nil, err
+	}
+	z.r = zr
+	return z, nil
+}
+
+// ReadContext implements ctxio.Reader, reading uncompressed bytes from its underlying Reader.
+func (z *ZlibReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return z.readContext(ctx, data)
+}
+
+// Reset discards the ZlibReader z's state and makes it equivalent to the
+// result of its original state from NewZlibReaderDict, but reading from r instead.
+// This permits reusing a ZlibReader rather than allocating a new one.
+func (z *ZlibReader) Reset(ctx context.Context, r ctxio.Reader, dict []byte) error {
+	z.br.R = r
+	z.br.Ctx = ctx
+	defer func() { z.br.Ctx = nil }()
+	return z.r.(zlib.Resetter).Reset(&z.br, dict)
+}
+
+// Close closes the ZlibReader. It does not close the underlying reader.
+func (z *ZlibReader) Close() error {
+	return z.r.Close()
+}
+
+// ZlibWriter is a ctxio.Writer that compresses data to the zlib format.
+type ZlibWriter struct {
+	writer
+	zw *zlib.Writer
+}
+
+// NewZlibWriter returns a new ZlibWriter.
+// Writes to the returned writer are compressed and written to w.
+func NewZlibWriter(w ctxio.Writer) *ZlibWriter {
+	z, _ := NewZlibWriterLevelDict(w, zlib.DefaultCompression, nil)
+	return z
+}
+
+// NewZlibWriterLevel is like NewZlibWriter but specifies the compression level.
+func NewZlibWriterLevel(w ctxio.Writer, level int) (*ZlibWriter, error) {
+	return NewZlibWriterLevelDict(w, level, nil)
+}
+
+// NewZlibWriterLevelDict is like NewZlibWriterLevel but specifies a dictionary to compress with.
+func NewZlibWriterLevelDict(w ctxio.Writer, level int, dict []byte) (*ZlibWriter, error) {
+	z := &ZlibWriter{}
+	z.bw.W = w
+	zw, err := zlib.NewWriterLevelDict(&z.bw, level, dict)
+	if err != nil {
+		return nil, err
+	}
+	z.zw = zw
+	z.w = zw
+	return z, nil
+}
+
+// WriteContext implements ctxio.Writer, writing a compressed form of data to the underlying Writer.
+func (z *ZlibWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	return z.writeContext(ctx, data)
+}
+
+// FlushContext flushes any pending compressed data to the underlying writer.
+func (z *ZlibWriter) FlushContext(ctx context.Context) error {
+	return z.flushContext(ctx)
+}
+
+// CloseContext closes the ZlibWriter by flushing any unwritten data to the underlying
+// writer and writing the zlib footer. It does not close the underlying writer.
+func (z *ZlibWriter) CloseContext(ctx context.Context) error {
+	return z.closeContext(ctx)
+}
+
+// Close is same as CloseContext with context.Background().
+func (z *ZlibWriter) Close() error {
+	return z.CloseContext(context.Background())
+}
+
+// Reset discards the ZlibWriter z's state and makes it equivalent to the
+// result of its original state, but writing to w instead.
+// This permits reusing a ZlibWriter rather than allocating a new one.
+func (z *ZlibWriter) Reset(w ctxio.Writer) {
+	z.bw.W = w
+	z.zw.Reset(&z.bw)
+}
@@ -0,0 +1,49 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/shogo82148/ctxio"
+)
+
+func TestZlib(t *testing.T) {
+	ctx := context.Background()
+	dict := []byte("hello")
+	var buf bytes.Buffer
+	zw, err := NewZlibWriterLevelDict(ctxio.NewWriter(&buf), 9, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.WriteContext(ctx, []byte("hello, world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.CloseContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	compressed := buf.Bytes()
+
+	zr, err := NewZlibReaderDict(ctx, ctxio.NewReader(bytes.NewReader(compressed)), dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ctxio.ReadAll(ctx, zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+
+	if err := zr.Reset(ctx, ctxio.NewReader(bytes.NewReader(compressed)), dict); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ctxio.ReadAll(ctx, zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+}
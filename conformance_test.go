@@ -0,0 +1,120 @@
+package ctxio_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+const content = "hello, world! 0123456789abcdefghijklmnopqrstuvwxyz"
+
+func TestReaders(t *testing.T) {
+	tests := []struct {
+		name string
+		r    func() ctxio.Reader
+	}{
+		{"NewReader", func() ctxio.Reader {
+			return ctxio.NewReader(strings.NewReader(content))
+		}},
+		{"CountingReader", func() ctxio.Reader {
+			return ctxio.NewCountingReader(ctxio.NewReader(strings.NewReader(content)))
+		}},
+		{"RateLimitReader", func() ctxio.Reader {
+			return ctxio.RateLimitReader(ctxio.NewReader(strings.NewReader(content)), ctxio.NewLimiter(ctxio.Inf, 7))
+		}},
+		{"IdleTimeoutReader", func() ctxio.Reader {
+			return ctxio.IdleTimeoutReader(ctxio.NewReader(strings.NewReader(content)), time.Minute)
+		}},
+		{"Pipe", func() ctxio.Reader {
+			pr, pw := ctxio.Pipe()
+			go func() {
+				ctxio.WriteStringContext(context.Background(), pw, content)
+				pw.Close()
+			}()
+			return pr
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ctxiotest.TestReader(tt.r(), []byte(content)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestReadersCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		r    func(t *testing.T) ctxio.Reader
+	}{
+		{"NewReader/net.Conn", func(t *testing.T) ctxio.Reader {
+			c1, c2 := net.Pipe()
+			t.Cleanup(func() { c1.Close(); c2.Close() })
+			return ctxio.NewReader(c1)
+		}},
+		{"NewReader/os.File", func(t *testing.T) ctxio.Reader {
+			pr, pw, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { pr.Close(); pw.Close() })
+			return ctxio.NewReader(pr)
+		}},
+		{"Pipe", func(t *testing.T) ctxio.Reader {
+			pr, pw := ctxio.Pipe()
+			t.Cleanup(func() { pr.Close(); pw.Close() })
+			return pr
+		}},
+		{"CountingReader", func(t *testing.T) ctxio.Reader {
+			return ctxio.NewCountingReader(ctxiotest.BlockingReader())
+		}},
+		{"IdleTimeoutReader", func(t *testing.T) ctxio.Reader {
+			return ctxio.IdleTimeoutReader(ctxiotest.BlockingReader(), time.Minute)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ctxiotest.TestReaderCancel(tt.r(t)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestWritersCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		w    func(t *testing.T) ctxio.Writer
+	}{
+		{"NewWriter/net.Conn", func(t *testing.T) ctxio.Writer {
+			c1, c2 := net.Pipe()
+			t.Cleanup(func() { c1.Close(); c2.Close() })
+			return ctxio.NewWriter(c1)
+		}},
+		{"Pipe", func(t *testing.T) ctxio.Writer {
+			pr, pw := ctxio.Pipe()
+			t.Cleanup(func() { pr.Close(); pw.Close() })
+			return pw
+		}},
+		{"CountingWriter", func(t *testing.T) ctxio.Writer {
+			return ctxio.NewCountingWriter(ctxiotest.BlockingWriter())
+		}},
+		{"IdleTimeoutWriter", func(t *testing.T) ctxio.Writer {
+			return ctxio.IdleTimeoutWriter(ctxiotest.BlockingWriter(), time.Minute)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ctxiotest.TestWriterCancel(tt.w(t)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Package ctxexec runs external commands with context-aware standard streams.
+//
+// The standard streams are connected through os.Pipe, which supports deadlines
+// on most platforms, so a canceled context interrupts a blocked read or write
+// without leaving a goroutine behind.
+package ctxexec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// Process is a command started by Start.
+type Process struct {
+	Cmd *exec.Cmd
+
+	// Stdin is connected to the standard input of the command.
+	// It is nil if Cmd.Stdin was set before Start.
+	// Close it to send EOF to the command.
+	Stdin ctxio.WriteCloser
+
+	// Stdout is connected to the standard output of the command.
+	// It is nil if Cmd.Stdout was set before Start.
+	Stdout ctxio.ReadCloser
+
+	// Stderr is connected to the standard error of the command.
+	// It is nil if Cmd.Stderr was set before Start.
+	Stderr ctxio.ReadCloser
+
+	closers []*pipe
+}
+
+// Start starts cmd with pipes connected to the standard streams
+// that are not set yet, and returns them in the Process.
+//
+// As with the pipes of exec.Cmd, Wait closes the pipes after the command exits,
+// so all reads from Stdout and Stderr must be completed before calling Wait.
+func Start(cmd *exec.Cmd) (*Process, error) {
+	p := &Process{Cmd: cmd}
+	var childEnds []*os.File
+	closeAll := func() {
+		for _, f := range childEnds {
+			f.Close()
+		}
+		for _, c := range p.closers {
+			c.Close()
+		}
+	}
+
+	if cmd.Stdin == nil {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		cmd.Stdin = pr
+		childEnds = append(childEnds, pr)
+		w := &pipe{f: pw, w: ctxio.NewWriter(pw)}
+		p.Stdin = w
+		p.closers = append(p.closers, w)
+	}
+	if cmd.Stdout == nil {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		cmd.Stdout = pw
+		childEnds = append(childEnds, pw)
+		r := &pipe{f: pr, r: ctxio.NewReader(pr)}
+		p.Stdout = r
+		p.closers = append(p.closers, r)
+	}
+	if cmd.Stderr == nil {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		cmd.Stderr = pw
+		childEnds = append(childEnds, pw)
+		r := &pipe{f: pr, r: ctxio.NewReader(pr)}
+		p.Stderr = r
+		p.closers = append(p.closers, r)
+	}
+
+	if err := cmd.Start(); err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	// the child has its own copies.
+	for _, f := range childEnds {
+		f.Close()
+	}
+	return p, nil
+}
+
+// Wait waits for the command to exit, and closes the pipes.
+func (p *Process) Wait() error {
+	err := p.Cmd.Wait()
+	for _, c := range p.closers {
+		c.Close()
+	}
+	return err
+}
+
+// pipe is the parent end of a pipe.
+type pipe struct {
+	f         *os.File
+	r         ctxio.ReadCloser
+	w         ctxio.WriteCloser
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func (p *pipe) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return p.r.ReadContext(ctx, data)
+}
+
+func (p *pipe) WriteContext(ctx context.Context, data []byte) (int, error) {
+	return p.w.WriteContext(ctx, data)
+}
+
+// Close closes the adapter and the file.
+func (p *pipe) Close() error {
+	p.closeOnce.Do(func() {
+		if p.r != nil {
+			p.r.Close()
+		}
+		if p.w != nil {
+			p.w.Close()
+		}
+		p.closeErr = p.f.Close()
+	})
+	return p.closeErr
+}
+
+// Output runs cmd, and returns its standard output and standard error.
+// The data read from stdin is copied to the standard input of the command.
+// If stdin is nil, the standard input is closed immediately.
+// The standard streams of cmd must not be set.
+//
+// If ctx is done before the command exits, the process is killed,
+// and Output returns ctx.Err() with the output collected so far.
+func Output(ctx context.Context, cmd *exec.Cmd, stdin ctxio.Reader) (stdout, stderr []byte, err error) {
+	if cmd.Stdin != nil || cmd.Stdout != nil || cmd.Stderr != nil {
+		return nil, nil, errors.New("ctxexec: standard streams already set")
+	}
+	p, err := Start(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// kill the process when ctx is done.
+	done := make(chan struct{})
+	killed := make(chan struct{})
+	go func() {
+		defer close(killed)
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var stdinErr, stdoutErr, stderrErr error
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		if stdin != nil {
+			_, stdinErr = ctxio.Copy(ctx, p.Stdin, stdin)
+		}
+		p.Stdin.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		stdout, stdoutErr = ctxio.ReadAll(ctx, p.Stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		stderr, stderrErr = ctxio.ReadAll(ctx, p.Stderr)
+	}()
+	wg.Wait()
+
+	err = p.Wait()
+	close(done)
+	<-killed
+
+	if ctx.Err() != nil {
+		return stdout, stderr, ctx.Err()
+	}
+	if err != nil {
+		return stdout, stderr, err
+	}
+	for _, e := range []error{stdoutErr, stderrErr} {
+		if e != nil {
+			return stdout, stderr, e
+		}
+	}
+	// the command may exit without reading its input.
+	if stdinErr != nil && !errors.Is(stdinErr, syscall.EPIPE) {
+		return stdout, stderr, stdinErr
+	}
+	return stdout, stderr, nil
+}
@@ -0,0 +1,115 @@
+package ctxexec
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+)
+
+func lookPath(t *testing.T, file string) {
+	t.Helper()
+	if _, err := exec.LookPath(file); err != nil {
+		t.Skipf("%s not found: %v", file, err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	lookPath(t, "cat")
+	ctx := context.Background()
+	p, err := Start(exec.Command("cat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		p.Stdin.WriteContext(ctx, []byte("hello, world"))
+		p.Stdin.Close()
+	}()
+	data, err := ctxio.ReadAll(ctx, p.Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStart_ReadCancel(t *testing.T) {
+	lookPath(t, "cat")
+	p, err := Start(exec.Command("cat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Wait()
+	defer p.Stdin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	buf := make([]byte, 64)
+	if _, err := p.Stdout.ReadContext(ctx, buf); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	lookPath(t, "sh")
+	src := ctxio.NewReader(strings.NewReader("hello"))
+	cmd := exec.Command("sh", "-c", "cat; echo error >&2")
+	stdout, stderr, err := Output(context.Background(), cmd, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stdout) != "hello" {
+		t.Errorf("want %q, got %q", "hello", stdout)
+	}
+	if string(stderr) != "error\n" {
+		t.Errorf("want %q, got %q", "error\n", stderr)
+	}
+}
+
+func TestOutput_ExitError(t *testing.T) {
+	lookPath(t, "sh")
+	cmd := exec.Command("sh", "-c", "exit 3")
+	_, _, err := Output(context.Background(), cmd, nil)
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("want *exec.ExitError, got %v", err)
+	}
+	if exitErr.ExitCode() != 3 {
+		t.Errorf("want exit code 3, got %d", exitErr.ExitCode())
+	}
+}
+
+func TestOutput_IgnoreUnreadInput(t *testing.T) {
+	lookPath(t, "sh")
+	src := ctxio.NewReader(strings.NewReader(strings.Repeat("x", 1<<20)))
+	cmd := exec.Command("sh", "-c", "exec 0<&-; echo done")
+	stdout, _, err := Output(context.Background(), cmd, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stdout) != "done\n" {
+		t.Errorf("want %q, got %q", "done\n", stdout)
+	}
+}
+
+func TestOutput_Cancel(t *testing.T) {
+	lookPath(t, "sleep")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := Output(ctx, exec.Command("sleep", "10"), nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Output took %s after cancel", d)
+	}
+}
@@ -0,0 +1,132 @@
+package ctxfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// FromFS returns an FS that accesses fsys.
+// The returned FS implements ReadDirFS and StatFS,
+// and the directories it opens implement ReadDirFile.
+//
+// The operations of fsys can't be interrupted, so they are called in other goroutines,
+// and the operations of the returned FS return as soon as the context is done.
+// A file opened after the context is done is closed.
+// The reads of the files use ctxio.NewReader.
+func FromFS(fsys fs.FS) FS {
+	return &adapterFS{fsys: fsys}
+}
+
+type adapterFS struct {
+	fsys fs.FS
+}
+
+func (a *adapterFS) OpenContext(ctx context.Context, name string) (File, error) {
+	f, err := call(ctx, "open", name, func() (fs.File, error) {
+		return a.fsys.Open(name)
+	}, func(f fs.File) {
+		f.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newFile(f, name), nil
+}
+
+func (a *adapterFS) ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error) {
+	return call(ctx, "readdir", name, func() ([]fs.DirEntry, error) {
+		return fs.ReadDir(a.fsys, name)
+	}, nil)
+}
+
+func (a *adapterFS) StatContext(ctx context.Context, name string) (fs.FileInfo, error) {
+	return call(ctx, "stat", name, func() (fs.FileInfo, error) {
+		return fs.Stat(a.fsys, name)
+	}, nil)
+}
+
+// file adapts fs.File to File.
+type file struct {
+	f    fs.File
+	r    ctxio.ReadCloser
+	name string
+
+	// mu serializes the calls of Stat and ReadDir of f,
+	// because a canceled call may still be running.
+	mu sync.Mutex
+}
+
+func newFile(f fs.File, name string) *file {
+	return &file{
+		f:    f,
+		r:    ctxio.NewReader(f),
+		name: name,
+	}
+}
+
+func (f *file) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return f.r.ReadContext(ctx, data)
+}
+
+func (f *file) StatContext(ctx context.Context) (fs.FileInfo, error) {
+	return call(ctx, "stat", f.name, func() (fs.FileInfo, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.f.Stat()
+	}, nil)
+}
+
+func (f *file) ReadDirContext(ctx context.Context, n int) ([]fs.DirEntry, error) {
+	dir, ok := f.f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not implemented")}
+	}
+	return call(ctx, "readdir", f.name, func() ([]fs.DirEntry, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return dir.ReadDir(n)
+	}, nil)
+}
+
+func (f *file) Close() error {
+	f.r.Close()
+	return f.f.Close()
+}
+
+// call calls fn in another goroutine, and waits for the result or ctx.
+// If ctx is done first, the error is reported as an *fs.PathError,
+// and cleanup is called with the result fn returns later if it succeeds.
+func call[T any](ctx context.Context, op, name string, fn func() (T, error), cleanup func(T)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	type result struct {
+		v   T
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		ch <- result{v, err}
+	}()
+
+	select {
+	case res := <-ch:
+		return res.v, res.err
+	case <-ctx.Done():
+		if cleanup != nil {
+			go func() {
+				if res := <-ch; res.err == nil {
+					cleanup(res.v)
+				}
+			}()
+		}
+		return zero, &fs.PathError{Op: op, Path: name, Err: ctx.Err()}
+	}
+}
@@ -0,0 +1,150 @@
+package ctxfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+)
+
+var testFS = fstest.MapFS{
+	"hello.txt":       {Data: []byte("hello, world")},
+	"dir/a.txt":       {Data: []byte("a")},
+	"dir/b.txt":       {Data: []byte("b")},
+	"dir/sub/c.txt":   {Data: []byte("c")},
+	"other/empty.txt": {Data: []byte{}},
+}
+
+func TestFromFS_MapFS(t *testing.T) {
+	ctx := context.Background()
+	fsys := FromFS(testFS)
+
+	f, err := fsys.OpenContext(ctx, "hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ctxio.ReadAll(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+	info, err := f.StatContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 12 {
+		t.Errorf("want 12, got %d", info.Size())
+	}
+
+	if _, err := fsys.OpenContext(ctx, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("want fs.ErrNotExist, got %v", err)
+	}
+
+	d, err := fsys.OpenContext(ctx, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	dir, ok := d.(ReadDirFile)
+	if !ok {
+		t.Fatal("want ReadDirFile")
+	}
+	entries, err := dir.ReadDirContext(ctx, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("want 3 entries, got %d", len(entries))
+	}
+}
+
+func TestFromFS_DirFS(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello, world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fsys := FromFS(os.DirFS(root))
+
+	f, err := fsys.OpenContext(ctx, "hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ctxio.ReadAll(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+
+	info, err := Stat(ctx, fsys, "hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "hello.txt" || info.Size() != 12 {
+		t.Errorf("unexpected FileInfo: %s, %d bytes", info.Name(), info.Size())
+	}
+}
+
+// slowFS is an fs.FS whose operations block until release is closed.
+type slowFS struct {
+	fs.FS
+	release chan struct{}
+	closed  chan string
+}
+
+func (s *slowFS) Open(name string) (fs.File, error) {
+	<-s.release
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &slowFile{File: f, name: name, closed: s.closed}, nil
+}
+
+type slowFile struct {
+	fs.File
+	name   string
+	closed chan string
+}
+
+func (f *slowFile) Close() error {
+	f.closed <- f.name
+	return f.File.Close()
+}
+
+func TestFromFS_Cancel(t *testing.T) {
+	slow := &slowFS{FS: testFS, release: make(chan struct{}), closed: make(chan string, 1)}
+	fsys := FromFS(slow)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := fsys.OpenContext(ctx, "hello.txt"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := ReadDir(ctx, fsys, "dir"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+
+	// the file opened after the cancellation is closed.
+	close(slow.release)
+	select {
+	case name := <-slow.closed:
+		if name != "hello.txt" {
+			t.Errorf("want hello.txt closed, got %s", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the file is not closed")
+	}
+}
@@ -0,0 +1,161 @@
+// Package ctxfs defines file system interfaces whose operations are canceled by contexts.
+//
+// It mirrors io/fs: FS, File, ReadDirFS and StatFS correspond to
+// fs.FS, fs.File, fs.ReadDirFS and fs.StatFS.
+// FromFS adapts any fs.FS, such as os.DirFS, to FS.
+package ctxfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// FS provides access to a hierarchical file system.
+// The names are the same as fs.FS; see fs.ValidPath.
+type FS interface {
+	// OpenContext opens the named file.
+	OpenContext(ctx context.Context, name string) (File, error)
+}
+
+// File provides access to a single file.
+// The reads are canceled by the context passed to ReadContext.
+type File interface {
+	ctxio.ReadCloser
+	StatContext(ctx context.Context) (fs.FileInfo, error)
+}
+
+// ReadDirFile is a directory file whose entries can be read with the ReadDirContext method.
+// It is the counterpart of fs.ReadDirFile.
+type ReadDirFile interface {
+	File
+
+	// ReadDirContext reads the contents of the directory.
+	// It has the same semantics as the ReadDir method of fs.ReadDirFile.
+	ReadDirContext(ctx context.Context, n int) ([]fs.DirEntry, error)
+}
+
+// ReadDirFS is the interface implemented by a file system
+// that provides an optimized implementation of ReadDir.
+type ReadDirFS interface {
+	FS
+
+	// ReadDirContext reads the named directory
+	// and returns a list of directory entries sorted by filename.
+	ReadDirContext(ctx context.Context, name string) ([]fs.DirEntry, error)
+}
+
+// StatFS is the interface implemented by a file system
+// that provides an optimized implementation of Stat.
+type StatFS interface {
+	FS
+
+	// StatContext returns a FileInfo describing the file.
+	StatContext(ctx context.Context, name string) (fs.FileInfo, error)
+}
+
+// ReadDir reads the named directory
+// and returns a list of directory entries sorted by filename.
+//
+// If fsys implements ReadDirFS, ReadDir calls fsys.ReadDirContext.
+// Otherwise ReadDir calls fsys.OpenContext and uses ReadDirContext of the returned file.
+func ReadDir(ctx context.Context, fsys FS, name string) ([]fs.DirEntry, error) {
+	if fsys, ok := fsys.(ReadDirFS); ok {
+		return fsys.ReadDirContext(ctx, name)
+	}
+
+	file, err := fsys.OpenContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dir, ok := file.(ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not implemented")}
+	}
+
+	list, err := dir.ReadDirContext(ctx, -1)
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, err
+}
+
+// Stat returns a FileInfo describing the named file from the file system.
+//
+// If fsys implements StatFS, Stat calls fsys.StatContext.
+// Otherwise, Stat opens the file to stat it.
+func Stat(ctx context.Context, fsys FS, name string) (fs.FileInfo, error) {
+	if fsys, ok := fsys.(StatFS); ok {
+		return fsys.StatContext(ctx, name)
+	}
+
+	file, err := fsys.OpenContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return file.StatContext(ctx)
+}
+
+// WalkDir walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root.
+// It has the same semantics as fs.WalkDir, except that it stops
+// and returns the error of ctx when ctx is done.
+func WalkDir(ctx context.Context, fsys FS, root string, fn fs.WalkDirFunc) error {
+	info, err := Stat(ctx, fsys, root)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(ctx, fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkDir(ctx context.Context, fsys FS, name string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			// Successfully skipped directory.
+			err = nil
+		}
+		return err
+	}
+
+	dirs, err := ReadDir(ctx, fsys, name)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Second call, to report ReadDir error.
+		err = fn(name, d, err)
+		if err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, d1 := range dirs {
+		name1 := path.Join(name, d1.Name())
+		if err := walkDir(ctx, fsys, name1, d1, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
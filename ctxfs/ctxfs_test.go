@@ -0,0 +1,142 @@
+package ctxfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadDir(t *testing.T) {
+	entries, err := ReadDir(context.Background(), FromFS(testFS), "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{"a.txt", "b.txt", "sub"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("want %v, got %v", want, names)
+	}
+}
+
+// openOnlyFS hides ReadDirContext and StatContext of an FS.
+type openOnlyFS struct {
+	FS
+}
+
+func TestReadDir_OpenOnly(t *testing.T) {
+	ctx := context.Background()
+	fsys := openOnlyFS{FromFS(testFS)}
+	entries, err := ReadDir(ctx, fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Name() != "a.txt" {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+
+	info, err := Stat(ctx, fsys, "dir/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Error("want a directory")
+	}
+}
+
+func TestWalkDir(t *testing.T) {
+	want := []string{".", "dir", "dir/a.txt", "dir/b.txt", "dir/sub", "dir/sub/c.txt", "hello.txt", "other", "other/empty.txt"}
+	for _, fsys := range []FS{FromFS(testFS), openOnlyFS{FromFS(testFS)}} {
+		var got []string
+		err := WalkDir(context.Background(), fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			got = append(got, path)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("want %v, got %v", want, got)
+		}
+	}
+}
+
+func TestWalkDir_DirFS(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "b", "c.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err := WalkDir(context.Background(), FromFS(os.DirFS(root)), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", "a", "a/b", "a/b/c.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestWalkDir_SkipDir(t *testing.T) {
+	var got []string
+	err := WalkDir(context.Background(), FromFS(testFS), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == "dir" {
+			return fs.SkipDir
+		}
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", "hello.txt", "other", "other/empty.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestWalkDir_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got []string
+	err := WalkDir(ctx, FromFS(testFS), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, path)
+		if path == "dir/a.txt" {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	want := []string{".", "dir", "dir/a.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,74 @@
+// Package ctxhttp integrates ctxio with net/http handlers.
+//
+// The request body and the response writer are adapted through
+// the read and write deadlines of http.ResponseController,
+// so a canceled context interrupts a blocked read or write of the connection.
+package ctxhttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// NewRequestBody returns the body of r as a ctxio.ReadCloser.
+// w must be the http.ResponseWriter passed to the handler with r.
+//
+// Closing the returned ReadCloser releases the resources of the adapter;
+// it doesn't close r.Body.
+func NewRequestBody(w http.ResponseWriter, r *http.Request) ctxio.ReadCloser {
+	rc := http.NewResponseController(w)
+	return ctxio.NewDeadlineReader(r.Body, rc)
+}
+
+// NewResponseWriter returns w as a ctxio.WriteCloser.
+//
+// The http.ResponseWriter buffers the response,
+// so writes may not block until the buffer is flushed.
+// Closing the returned WriteCloser releases the resources of the adapter;
+// it doesn't finish the response.
+func NewResponseWriter(w http.ResponseWriter) ctxio.WriteCloser {
+	rc := http.NewResponseController(w)
+	return ctxio.NewDeadlineWriter(w, rc)
+}
+
+type contextKey struct {
+	name string
+}
+
+var (
+	requestBodyKey    = &contextKey{"request-body"}
+	responseWriterKey = &contextKey{"response-writer"}
+)
+
+// Middleware returns a handler that installs the adapters returned by
+// NewRequestBody and NewResponseWriter into the request context, and calls next.
+// The handler can get them by RequestBody and ResponseWriter.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := NewRequestBody(w, r)
+		defer body.Close()
+		rw := NewResponseWriter(w)
+		defer rw.Close()
+
+		ctx := r.Context()
+		ctx = context.WithValue(ctx, requestBodyKey, body)
+		ctx = context.WithValue(ctx, responseWriterKey, rw)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestBody returns the request body installed by Middleware.
+// It returns nil if Middleware is not used.
+func RequestBody(r *http.Request) ctxio.Reader {
+	body, _ := r.Context().Value(requestBodyKey).(ctxio.Reader)
+	return body
+}
+
+// ResponseWriter returns the response writer installed by Middleware.
+// It returns nil if Middleware is not used.
+func ResponseWriter(r *http.Request) ctxio.Writer {
+	rw, _ := r.Context().Value(responseWriterKey).(ctxio.Writer)
+	return rw
+}
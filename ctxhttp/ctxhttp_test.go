@@ -0,0 +1,143 @@
+package ctxhttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+)
+
+func TestMiddleware(t *testing.T) {
+	ts := httptest.NewServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		data, err := ctxio.ReadAll(ctx, RequestBody(r))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := ResponseWriter(r).WriteContext(ctx, bytes.ToUpper(data)); err != nil {
+			t.Error(err)
+		}
+	})))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL, "text/plain", strings.NewReader("hello, world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "HELLO, WORLD" {
+		t.Errorf("want %q, got %q", "HELLO, WORLD", data)
+	}
+}
+
+func TestRequestBody_Cancel(t *testing.T) {
+	result := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := NewRequestBody(w, r)
+		defer body.Close()
+
+		ctx, cancel := context.WithTimeout(r.Context(), 50*time.Millisecond)
+		defer cancel()
+		_, err := body.ReadContext(ctx, make([]byte, 64))
+		result <- err
+	}))
+	defer ts.Close()
+
+	// send a request whose body never comes.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	req, err := http.NewRequest(http.MethodPost, ts.URL, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("want context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the read is not canceled")
+	}
+}
+
+func TestResponseWriter_Cancel(t *testing.T) {
+	result := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := NewResponseWriter(w)
+		defer rw.Close()
+
+		ctx, cancel := context.WithTimeout(r.Context(), 100*time.Millisecond)
+		defer cancel()
+		data := make([]byte, 1<<20)
+		for {
+			if _, err := rw.WriteContext(ctx, data); err != nil {
+				result <- err
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	// send a request and never read the response.
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(conn)
+	if err := req.Write(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("want context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the write is not canceled")
+	}
+}
+
+func TestNewRequestBody_NotSupported(t *testing.T) {
+	// httptest.ResponseRecorder doesn't support deadlines.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	body := NewRequestBody(w, r)
+	defer body.Close()
+	data, err := ctxio.ReadAll(context.Background(), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("want %q, got %q", "hello", data)
+	}
+}
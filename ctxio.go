@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"io"
-	"sync"
 	"time"
 )
 
@@ -85,6 +84,8 @@ func ReadFull(ctx context.Context, r Reader, buf []byte) (n int, err error) {
 // A successful Copy returns err == nil, not err == EOF.
 // Because Copy is defined to read from src until EOF, it does
 // not treat an EOF from Read as an error to be reported.
+// Other errors are reported as an *OpError with Op "copy",
+// telling which side of the copy failed.
 func Copy(ctx context.Context, dst Writer, src Reader) (written int64, err error) {
 	return copyBuffer(ctx, dst, src, nil)
 }
@@ -106,16 +107,19 @@ func copyBuffer(ctx context.Context, dst Writer, src Reader, buf []byte) (writte
 	// If the reader has a WriteToContext method, use it to do the copy.
 	// Avoids an allocation and a copy.
 	if wt, ok := src.(WriterTo); ok {
-		return wt.WriteToContext(ctx, dst)
+		written, err = wt.WriteToContext(ctx, dst)
+		return written, copyError(written, err)
 	}
 	// Similarly, if the writer has a ReadFromContext method, use it to do the copy.
 	if rt, ok := dst.(ReaderFrom); ok {
-		return rt.ReadFromContext(ctx, src)
+		written, err = rt.ReadFromContext(ctx, src)
+		return written, copyError(written, err)
 	}
 
 	if buf == nil {
-		size := 32 * 1024
-		buf = make([]byte, size)
+		pool := BufferPoolFromContext(ctx)
+		buf = pool.Get(32 * 1024)
+		defer pool.Put(buf)
 	}
 	for {
 		nr, er := src.ReadContext(ctx, buf)
@@ -126,20 +130,20 @@ func copyBuffer(ctx context.Context, dst Writer, src Reader, buf []byte) (writte
 				if ew == nil {
 					ew = errInvalidWrite
 				}
-				written += int64(nw)
 			}
+			written += int64(nw)
 			if ew != nil {
-				err = ew
+				err = rewrapError(OpCopy, SideDst, written, ew)
 				break
 			}
 			if nr != nw {
-				err = io.ErrShortWrite
+				err = wrapError(OpCopy, SideDst, written, io.ErrShortWrite)
 				break
 			}
 		}
 		if er != nil {
 			if er != io.EOF {
-				err = er
+				err = rewrapError(OpCopy, SideSrc, written, er)
 			}
 			break
 		}
@@ -177,25 +181,19 @@ func (discard) WriteStringContext(ctx context.Context, s string) (int, error) {
 	return len(s), nil
 }
 
-var blackHolePool = sync.Pool{
-	New: func() any {
-		b := make([]byte, 8192)
-		return &b
-	},
-}
-
 func (discard) ReadFromContext(ctx context.Context, r Reader) (n int64, err error) {
-	bufp := blackHolePool.Get().(*[]byte)
+	pool := BufferPoolFromContext(ctx)
+	buf := pool.Get(8192)
 	readSize := 0
 	for {
-		readSize, err = r.ReadContext(ctx, *bufp)
+		readSize, err = r.ReadContext(ctx, buf)
 		n += int64(readSize)
 		if err != nil {
-			blackHolePool.Put(bufp)
+			pool.Put(buf)
 			if err == io.EOF {
 				return n, nil
 			}
-			return
+			return n, rewrapError(OpRead, "", n, err)
 		}
 	}
 }
@@ -228,10 +226,102 @@ func (c nopCloserWriterTo) WriteToContext(ctx context.Context, w Writer) (n int6
 // ReadAll reads from r until an error or io.EOF and returns the data it read.
 // A successful call returns err == nil, not err == io.EOF. Because ReadAll is
 // defined to read from src until io.EOF, it does not treat an io.EOF from Read
-// as an error to be reported.
+// as an error to be reported. Other errors are reported as an *OpError.
+//
+// The buffers outgrown while reading are returned to the BufferPool of ctx.
 func ReadAll(ctx context.Context, r Reader) ([]byte, error) {
-	b := make([]byte, 0, 512)
+	pool := BufferPoolFromContext(ctx)
+	b := pool.Get(512)[:0]
 	for {
+		if len(b) == cap(b) {
+			// Add more capacity.
+			nb := pool.Get(2 * cap(b))[:len(b)]
+			copy(nb, b)
+			pool.Put(b)
+			b = nb
+		}
+		n, err := r.ReadContext(ctx, b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err != nil {
+			if err == io.EOF {
+				return b, nil
+			}
+			return b, wrapError(OpRead, SideSrc, int64(len(b)), err)
+		}
+	}
+}
+
+// ErrTooLarge is returned by ReadAllLimit when the data exceeds the limit.
+var ErrTooLarge = errors.New("ctxio: data too large")
+
+// ReadAllLimit is like ReadAll, but reads at most limit bytes.
+// If r has more data, ReadAllLimit returns the first limit bytes
+// and an *OpError wrapping ErrTooLarge; r has been read one byte beyond the limit then.
+//
+// ctx is checked before each read, so ReadAllLimit returns soon after ctx is done
+// even if r ignores ctx.
+func ReadAllLimit(ctx context.Context, r Reader, limit int64) ([]byte, error) {
+	if limit < 0 {
+		limit = 0
+	}
+	max := limit + 1 // read one more byte to detect the excess.
+	if max < 0 {
+		max = limit
+	}
+
+	pool := BufferPoolFromContext(ctx)
+	size := int64(512)
+	if size > max {
+		size = max
+	}
+	b := pool.Get(int(size))[:0]
+	for {
+		if err := ctx.Err(); err != nil {
+			return b, wrapError(OpRead, SideSrc, int64(len(b)), err)
+		}
+		end := int64(cap(b))
+		if end > max {
+			end = max
+		}
+		if int64(len(b)) == end {
+			// Add more capacity up to max.
+			size := 2 * int64(cap(b))
+			if size > max {
+				size = max
+			}
+			nb := pool.Get(int(size))[:len(b)]
+			copy(nb, b)
+			pool.Put(b)
+			b = nb
+			continue
+		}
+		n, err := r.ReadContext(ctx, b[len(b):end])
+		b = b[:len(b)+n]
+		if int64(len(b)) > limit {
+			return b[:limit], wrapError(OpRead, SideSrc, limit, ErrTooLarge)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return b, nil
+			}
+			return b, wrapError(OpRead, SideSrc, int64(len(b)), err)
+		}
+	}
+}
+
+// AppendAll reads from r until an error or io.EOF, appends the data to dst,
+// and returns the extended slice. The spare capacity of dst is used before
+// allocating a larger slice, so callers can reuse a slice across calls.
+// The errors are reported as ReadAll does.
+//
+// ctx is checked before each read, so AppendAll returns soon after ctx is done
+// even if r ignores ctx.
+func AppendAll(ctx context.Context, dst []byte, r Reader) ([]byte, error) {
+	b := dst
+	for {
+		if err := ctx.Err(); err != nil {
+			return b, wrapError(OpRead, SideSrc, int64(len(b)-len(dst)), err)
+		}
 		if len(b) == cap(b) {
 			// Add more capacity (let append pick how much).
 			b = append(b, 0)[:len(b)]
@@ -240,9 +330,9 @@ func ReadAll(ctx context.Context, r Reader) ([]byte, error) {
 		b = b[:len(b)+n]
 		if err != nil {
 			if err == io.EOF {
-				err = nil
+				return b, nil
 			}
-			return b, err
+			return b, wrapError(OpRead, SideSrc, int64(len(b)-len(dst)), err)
 		}
 	}
 }
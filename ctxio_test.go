@@ -3,6 +3,7 @@ package ctxio
 import (
 	"bytes"
 	"context"
+	"errors"
 	"testing"
 )
 
@@ -18,11 +19,39 @@ func (buf *Buffer) WriteContext(ctx context.Context, data []byte) (int, error) {
 	return buf.Write(data)
 }
 
+// writerToBuffer is a Buffer that implements WriterTo.
+type writerToBuffer struct {
+	Buffer
+	called bool
+}
+
+func (b *writerToBuffer) WriteToContext(ctx context.Context, w Writer) (int64, error) {
+	b.called = true
+	return copyBuffer(ctx, w, readerOnly{&b.Buffer}, make([]byte, 4))
+}
+
+// readerFromBuffer is a Buffer that implements ReaderFrom.
+type readerFromBuffer struct {
+	Buffer
+	called bool
+}
+
+func (b *readerFromBuffer) ReadFromContext(ctx context.Context, r Reader) (int64, error) {
+	b.called = true
+	return copyBuffer(ctx, writerOnly{&b.Buffer}, r, make([]byte, 4))
+}
+
 func TestCopy(t *testing.T) {
 	rb := new(Buffer)
 	wb := new(Buffer)
 	rb.WriteString("hello, world.")
-	Copy(context.Background(), wb, rb)
+	n, err := Copy(context.Background(), wb, rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 13 {
+		t.Errorf("want 13, got %d", n)
+	}
 	if wb.String() != "hello, world." {
 		t.Errorf("Copy did not work properly")
 	}
@@ -59,3 +88,82 @@ func TestReadAll(t *testing.T) {
 		t.Errorf("ReadAll did not work properly")
 	}
 }
+
+func TestReadAllLimit(t *testing.T) {
+	for _, tt := range []struct {
+		limit   int64
+		want    string
+		wantErr error
+	}{
+		{13, "hello, world.", nil},
+		{100, "hello, world.", nil},
+		{12, "hello, world", ErrTooLarge},
+		{0, "", ErrTooLarge},
+	} {
+		rb := new(Buffer)
+		rb.WriteString("hello, world.")
+		data, err := ReadAllLimit(context.Background(), rb, tt.limit)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("limit %d: want %v, got %v", tt.limit, tt.wantErr, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("limit %d: want %q, got %q", tt.limit, tt.want, data)
+		}
+	}
+}
+
+func TestAppendAll(t *testing.T) {
+	rb := new(Buffer)
+	rb.WriteString("hello, world.")
+	dst := make([]byte, 0, 64)
+	dst = append(dst, "> "...)
+	data, err := AppendAll(context.Background(), dst, rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "> hello, world." {
+		t.Errorf("want %q, got %q", "> hello, world.", data)
+	}
+	if &data[0] != &dst[0] {
+		t.Error("the spare capacity of dst is not used")
+	}
+}
+
+// endlessReader returns data forever, ignoring the context.
+// It calls cancel after 10 reads.
+type endlessReader struct {
+	reads  int
+	cancel context.CancelFunc
+}
+
+func (r *endlessReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	r.reads++
+	if r.reads == 10 {
+		r.cancel()
+	}
+	for i := range data {
+		data[i] = 'x'
+	}
+	return len(data), nil
+}
+
+func TestReadAll_IgnoredContext(t *testing.T) {
+	for name, readAll := range map[string]func(context.Context, Reader) ([]byte, error){
+		"ReadAllLimit": func(ctx context.Context, r Reader) ([]byte, error) {
+			return ReadAllLimit(ctx, r, 1<<30)
+		},
+		"AppendAll": func(ctx context.Context, r Reader) ([]byte, error) {
+			return AppendAll(ctx, nil, r)
+		},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &endlessReader{cancel: cancel}
+		_, err := readAll(ctx, r)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: want context.Canceled, got %v", name, err)
+		}
+		if r.reads != 10 {
+			t.Errorf("%s: want 10 reads, got %d", name, r.reads)
+		}
+	}
+}
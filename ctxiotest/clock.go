@@ -0,0 +1,220 @@
+package ctxiotest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// FakeClock is a ctxio.Clock that only moves when Advance is called.
+// Pass it to the code under test with ctxio.WithClock.
+//
+// Timers fire on the goroutine calling Advance, and functions registered by
+// AfterFunc have returned when Advance returns, so tests can drive
+// idle timeouts, rate limits and deadlines without sleeping.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+var _ ctxio.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock whose current time is now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond.L = &c.mu
+	return c
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates a new Timer that sends the current time on its channel
+// when the clock is advanced by at least d.
+func (c *FakeClock) NewTimer(d time.Duration) ctxio.Timer {
+	return c.addTimer(&fakeTimer{c: c, ch: make(chan time.Time, 1)}, d)
+}
+
+// AfterFunc waits for the clock to be advanced by at least d, and then calls f
+// on the goroutine calling Advance.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) ctxio.Timer {
+	return c.addTimer(&fakeTimer{c: c, fn: f}, d)
+}
+
+func (c *FakeClock) addTimer(t *fakeTimer, d time.Duration) *fakeTimer {
+	c.mu.Lock()
+	t.when = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	if d <= 0 {
+		c.Advance(0)
+	}
+	return t
+}
+
+// Advance moves the clock forward by d, and fires the expired timers
+// in the order of their expiration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var fired, timers []*fakeTimer
+	for _, t := range c.timers {
+		if t.when.After(now) {
+			timers = append(timers, t)
+		} else {
+			fired = append(fired, t)
+		}
+	}
+	c.timers = timers
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	sortTimers(fired)
+	for _, t := range fired {
+		if t.fn != nil {
+			t.fn()
+		} else {
+			t.ch <- now
+		}
+	}
+}
+
+func sortTimers(timers []*fakeTimer) {
+	// insertion sort; stable, and the number of timers is small.
+	for i := 1; i < len(timers); i++ {
+		for j := i; j > 0 && timers[j].when.Before(timers[j-1].when); j-- {
+			timers[j], timers[j-1] = timers[j-1], timers[j]
+		}
+	}
+}
+
+// Timers returns the number of active timers.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n timers are active.
+// It lets a test wait for the code under test to start waiting on the clock
+// before advancing it.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// WithDeadline is like context.WithDeadline, but the deadline is measured by c.
+// The returned context also carries c, see ctxio.WithClock.
+func (c *FakeClock) WithDeadline(parent context.Context, d time.Time) (context.Context, context.CancelFunc) {
+	return c.WithTimeout(parent, d.Sub(c.Now()))
+}
+
+// WithTimeout is like context.WithTimeout, but the timeout is measured by c.
+// The returned context also carries c, see ctxio.WithClock.
+func (c *FakeClock) WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx := &deadlineContext{
+		parent:   ctxio.WithClock(parent, c),
+		deadline: c.Now().Add(timeout),
+		done:     make(chan struct{}),
+	}
+	if err := parent.Err(); err != nil {
+		ctx.cancel(err)
+		return ctx, func() {}
+	}
+	timer := c.AfterFunc(timeout, func() { ctx.cancel(context.DeadlineExceeded) })
+	ctx.mu.Lock()
+	ctx.timer = timer
+	ctx.mu.Unlock()
+	go func() {
+		select {
+		case <-parent.Done():
+			ctx.cancel(parent.Err())
+		case <-ctx.done:
+		}
+	}()
+	return ctx, func() { ctx.cancel(context.Canceled) }
+}
+
+// deadlineContext is a context whose deadline is measured by a FakeClock.
+// It doesn't embed a context created by the context package,
+// so that its children observe the error returned by Err.
+type deadlineContext struct {
+	parent   context.Context
+	deadline time.Time
+	done     chan struct{}
+	timer    ctxio.Timer
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *deadlineContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *deadlineContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *deadlineContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *deadlineContext) Value(key any) any {
+	return c.parent.Value(key)
+}
+
+func (c *deadlineContext) cancel(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	close(c.done)
+	timer := c.timer
+	c.mu.Unlock()
+
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+type fakeTimer struct {
+	c    *FakeClock
+	ch   chan time.Time
+	fn   func()
+	when time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	for i, tt := range t.c.timers {
+		if tt == t {
+			t.c.timers = append(t.c.timers[:i], t.c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package ctxiotest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+)
+
+var epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClock_Timer(t *testing.T) {
+	c := NewFakeClock(epoch)
+	t1 := c.NewTimer(2 * time.Second)
+	var order []int
+	c.AfterFunc(time.Second, func() { order = append(order, 1) })
+	c.AfterFunc(3*time.Second, func() { order = append(order, 3) })
+	if c.Timers() != 3 {
+		t.Fatalf("want 3 timers, got %d", c.Timers())
+	}
+
+	c.Advance(2 * time.Second)
+	if len(order) != 1 || order[0] != 1 {
+		t.Errorf("want [1], got %v", order)
+	}
+	select {
+	case now := <-t1.C():
+		if !now.Equal(epoch.Add(2 * time.Second)) {
+			t.Errorf("want %v, got %v", epoch.Add(2*time.Second), now)
+		}
+	default:
+		t.Error("the timer is not fired")
+	}
+	if t1.Stop() {
+		t.Error("want false, got true")
+	}
+	if c.Timers() != 1 {
+		t.Errorf("want 1 timer, got %d", c.Timers())
+	}
+}
+
+func TestFakeClock_WithTimeout(t *testing.T) {
+	c := NewFakeClock(epoch)
+	ctx, cancel := c.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(epoch.Add(time.Second)) {
+		t.Errorf("want %v, got %v, %v", epoch.Add(time.Second), d, ok)
+	}
+	if ctxio.ClockFromContext(ctx) != c {
+		t.Error("the context doesn't carry the clock")
+	}
+
+	child, cancelChild := context.WithCancel(ctx)
+	defer cancelChild()
+
+	c.Advance(999 * time.Millisecond)
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	c.Advance(time.Millisecond)
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+	<-child.Done()
+	if err := child.Err(); err != context.DeadlineExceeded {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestFakeClock_WithTimeoutCancel(t *testing.T) {
+	c := NewFakeClock(epoch)
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := c.WithTimeout(parent, time.Second)
+	defer cancel()
+	cancelParent()
+	<-ctx.Done()
+	if err := ctx.Err(); err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if c.Timers() != 0 {
+		t.Error("the timer is not stopped")
+	}
+}
+
+func TestFakeClock_Pipe(t *testing.T) {
+	c := NewFakeClock(epoch)
+	r, w := ctxio.Pipe()
+	defer r.Close()
+	defer w.Close()
+
+	ctx, cancel := c.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.ReadContext(ctx, make([]byte, 16))
+		done <- err
+	}()
+	c.Advance(time.Second)
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestFakeClock_IdleTimeout(t *testing.T) {
+	// the fake clock drives the idle timeout of a reader with read deadlines.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+	r := ctxio.NewReader(pr)
+	defer r.Close()
+
+	c := NewFakeClock(epoch)
+	ctx := ctxio.WithClock(context.Background(), c)
+	ir := ctxio.IdleTimeoutReader(r, time.Minute)
+	done := make(chan error, 1)
+	go func() {
+		_, err := ir.ReadContext(ctx, make([]byte, 16))
+		done <- err
+	}()
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	if err := <-done; !errors.Is(err, ctxio.ErrIdleTimeout) {
+		t.Errorf("want ErrIdleTimeout, got %v", err)
+	}
+}
+
+func TestFakeClock_Limiter(t *testing.T) {
+	c := NewFakeClock(epoch)
+	ctx := context.Background()
+	l := ctxio.NewLimiterWithClock(10, 10, c)
+	if err := l.WaitN(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- l.WaitN(ctx, 10)
+	}()
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,233 @@
+// Package ctxiotest implements Readers and Writers useful mainly for testing,
+// in the same way as testing/iotest does for the io package.
+package ctxiotest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// OneByteReader returns a Reader that implements
+// each non-empty Read by reading one byte from r.
+func OneByteReader(r ctxio.Reader) ctxio.Reader { return &oneByteReader{r} }
+
+type oneByteReader struct {
+	r ctxio.Reader
+}
+
+func (r *oneByteReader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.r.ReadContext(ctx, p[0:1])
+}
+
+// HalfReader returns a Reader that implements Read
+// by reading half as many requested bytes from r.
+func HalfReader(r ctxio.Reader) ctxio.Reader { return &halfReader{r} }
+
+type halfReader struct {
+	r ctxio.Reader
+}
+
+func (r *halfReader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	return r.r.ReadContext(ctx, p[0:(len(p)+1)/2])
+}
+
+// DataErrReader changes the way errors are handled by a Reader. Normally, a
+// Reader returns an error (typically EOF) from the first Read call after the
+// last piece of data is read. DataErrReader wraps a Reader and changes its
+// behavior so the final error is returned along with the final data, instead
+// of in the first call after the final data.
+func DataErrReader(r ctxio.Reader) ctxio.Reader { return &dataErrReader{r, nil, make([]byte, 1024)} }
+
+type dataErrReader struct {
+	r      ctxio.Reader
+	unread []byte
+	data   []byte
+}
+
+func (r *dataErrReader) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	// loop because first call needs two reads:
+	// one to get data and a second to look for an error.
+	for {
+		if len(r.unread) == 0 {
+			n1, err1 := r.r.ReadContext(ctx, r.data)
+			r.unread = r.data[0:n1]
+			err = err1
+		}
+		if n > 0 || err != nil {
+			break
+		}
+		n = copy(p, r.unread)
+		r.unread = r.unread[n:]
+	}
+	return
+}
+
+// ErrTimeout is a fake timeout error.
+var ErrTimeout = errors.New("timeout")
+
+// TimeoutReader returns ErrTimeout on the second read
+// with no data. Subsequent calls to read succeed.
+func TimeoutReader(r ctxio.Reader) ctxio.Reader { return &timeoutReader{r, 0} }
+
+type timeoutReader struct {
+	r     ctxio.Reader
+	count int
+}
+
+func (r *timeoutReader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	r.count++
+	if r.count == 2 {
+		return 0, ErrTimeout
+	}
+	return r.r.ReadContext(ctx, p)
+}
+
+// ErrReader returns a Reader that returns 0, err from all Read calls.
+func ErrReader(err error) ctxio.Reader {
+	return &errReader{err: err}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	return 0, r.err
+}
+
+// BlockingReader returns a Reader that blocks until ctx is done,
+// and returns 0, ctx.Err() from all Read calls.
+func BlockingReader() ctxio.Reader {
+	return blockingReader{}
+}
+
+type blockingReader struct{}
+
+func (blockingReader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+type smallByteReader struct {
+	r   ctxio.Reader
+	off int
+	n   int
+}
+
+func (r *smallByteReader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	r.n = r.n%3 + 1
+	n := r.n
+	if n > len(p) {
+		n = len(p)
+	}
+	n, err := r.r.ReadContext(ctx, p[0:n])
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("Read(%d bytes at offset %d): %v", n, r.off, err)
+	}
+	r.off += n
+	return n, err
+}
+
+// TestReader tests that reading from r returns the expected file content.
+// It does reads of different sizes, until EOF.
+//
+// If TestReader finds any misbehaviors, it returns an error reporting them.
+// The error text may span multiple lines.
+func TestReader(r ctxio.Reader, content []byte) error {
+	ctx := context.Background()
+	if len(content) > 0 {
+		n, err := r.ReadContext(ctx, nil)
+		if n != 0 || err != nil {
+			return fmt.Errorf("Read(0) = %d, %v, want 0, nil", n, err)
+		}
+	}
+
+	data, err := ctxio.ReadAll(ctx, &smallByteReader{r: r})
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(data, content) {
+		return fmt.Errorf("ReadAll(small amounts) = %q\n\twant %q", data, content)
+	}
+	n, err := r.ReadContext(ctx, make([]byte, 10))
+	if n != 0 || err != io.EOF {
+		return fmt.Errorf("Read(10) at EOF = %v, %v, want 0, EOF", n, err)
+	}
+	n, err = r.ReadContext(ctx, make([]byte, 10))
+	if n != 0 || err != io.EOF {
+		return fmt.Errorf("second Read(10) at EOF = %v, %v, want 0, EOF", n, err)
+	}
+	return nil
+}
+
+// cancelTimeout is how long TestReaderCancel and TestWriterCancel wait
+// for an operation to return after cancellation.
+const cancelTimeout = 5 * time.Second
+
+// TestReaderCancel tests that a read from r, which must have no data available,
+// returns an error wrapping the cause of the cancellation,
+// both if the context is done before the call and during the call.
+func TestReaderCancel(r ctxio.Reader) error {
+	return testCancel("Read", func(ctx context.Context) (int, error) {
+		return r.ReadContext(ctx, make([]byte, 10))
+	})
+}
+
+func testCancel(op string, f func(ctx context.Context) (int, error)) error {
+	// canceled before the call.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err := callWithTimeout(ctx, f)
+	if err == errNotReturned {
+		return fmt.Errorf("%s with a canceled context didn't return", op)
+	}
+	if n != 0 || !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%s with a canceled context = %d, %v, want 0, context.Canceled", op, n, err)
+	}
+
+	// canceled during the call.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	n, err = callWithTimeout(ctx, f)
+	if err == errNotReturned {
+		return fmt.Errorf("%s didn't return after the context is done", op)
+	}
+	if n != 0 || !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s after the deadline = %d, %v, want 0, context.DeadlineExceeded", op, n, err)
+	}
+	return nil
+}
+
+var errNotReturned = errors.New("not returned")
+
+func callWithTimeout(ctx context.Context, f func(ctx context.Context) (int, error)) (int, error) {
+	type result struct {
+		n   int
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		n, err := f(ctx)
+		ch <- result{n, err}
+	}()
+	t := time.NewTimer(cancelTimeout)
+	defer t.Stop()
+	select {
+	case res := <-ch:
+		return res.n, res.err
+	case <-t.C:
+		return 0, errNotReturned
+	}
+}
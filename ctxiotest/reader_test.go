@@ -0,0 +1,129 @@
+package ctxiotest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/shogo82148/ctxio"
+)
+
+func newReader(s string) ctxio.Reader {
+	return ctxio.NewReader(strings.NewReader(s))
+}
+
+func TestOneByteReader(t *testing.T) {
+	r := OneByteReader(newReader("hello"))
+	b := make([]byte, 10)
+	for i := 0; i < 5; i++ {
+		n, err := r.ReadContext(context.Background(), b)
+		if n != 1 || err != nil {
+			t.Fatalf("Read = %d, %v, want 1, nil", n, err)
+		}
+		if b[0] != "hello"[i] {
+			t.Errorf("want %q, got %q", "hello"[i], b[0])
+		}
+	}
+	if n, err := r.ReadContext(context.Background(), b); n != 0 || err != io.EOF {
+		t.Errorf("Read at EOF = %d, %v, want 0, EOF", n, err)
+	}
+	if n, err := r.ReadContext(context.Background(), nil); n != 0 || err != nil {
+		t.Errorf("Read(nil) = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestHalfReader(t *testing.T) {
+	r := HalfReader(newReader("hello, world"))
+	b := make([]byte, 10)
+	n, err := r.ReadContext(context.Background(), b)
+	if n != 5 || err != nil {
+		t.Fatalf("Read = %d, %v, want 5, nil", n, err)
+	}
+	if string(b[:n]) != "hello" {
+		t.Errorf("want %q, got %q", "hello", b[:n])
+	}
+}
+
+func TestDataErrReader(t *testing.T) {
+	r := DataErrReader(newReader("hello"))
+	b := make([]byte, 10)
+	n, err := r.ReadContext(context.Background(), b)
+	if n != 5 || err != io.EOF {
+		t.Fatalf("Read = %d, %v, want 5, EOF", n, err)
+	}
+	if string(b[:n]) != "hello" {
+		t.Errorf("want %q, got %q", "hello", b[:n])
+	}
+}
+
+func TestTimeoutReader(t *testing.T) {
+	r := TimeoutReader(newReader("hello, world"))
+	b := make([]byte, 5)
+	if _, err := r.ReadContext(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadContext(context.Background(), b); err != ErrTimeout {
+		t.Errorf("want ErrTimeout, got %v", err)
+	}
+	if _, err := r.ReadContext(context.Background(), b); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
+
+func TestErrReader(t *testing.T) {
+	errTest := errors.New("test error")
+	r := ErrReader(errTest)
+	if n, err := r.ReadContext(context.Background(), make([]byte, 10)); n != 0 || err != errTest {
+		t.Errorf("Read = %d, %v, want 0, %v", n, err, errTest)
+	}
+}
+
+func TestBlockingReader(t *testing.T) {
+	if err := TestReaderCancel(BlockingReader()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTestReader(t *testing.T) {
+	content := []byte("hello, world")
+	if err := TestReader(newReader(string(content)), content); err != nil {
+		t.Error(err)
+	}
+	if err := TestReader(OneByteReader(newReader(string(content))), content); err != nil {
+		t.Error(err)
+	}
+	if err := TestReader(newReader(""), nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTestReader_Misbehaving(t *testing.T) {
+	content := []byte("hello, world")
+	tests := []struct {
+		name string
+		r    ctxio.Reader
+	}{
+		{"wrong content", newReader("hello, WORLD")},
+		{"short content", newReader("hello")},
+		{"error", DataErrReader(ErrReader(errors.New("test error")))},
+		{"timeout", TimeoutReader(newReader(string(content)))},
+	}
+	for _, tt := range tests {
+		if err := TestReader(tt.r, content); err == nil {
+			t.Errorf("%s: want error, got nil", tt.name)
+		}
+	}
+}
+
+func TestTestReaderCancel_Misbehaving(t *testing.T) {
+	if err := TestReaderCancel(newReader("")); err == nil {
+		t.Error("want error, got nil")
+	}
+	r := DataErrReader(newReader(string(bytes.Repeat([]byte("x"), 100))))
+	if err := TestReaderCancel(r); err == nil {
+		t.Error("want error, got nil")
+	}
+}
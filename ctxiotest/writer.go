@@ -0,0 +1,90 @@
+package ctxiotest
+
+import (
+	"context"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// TruncateWriter returns a Writer that writes to w
+// but stops silently after n bytes.
+func TruncateWriter(w ctxio.Writer, n int64) ctxio.Writer {
+	return &truncateWriter{w, n}
+}
+
+type truncateWriter struct {
+	w ctxio.Writer
+	n int64
+}
+
+func (t *truncateWriter) WriteContext(ctx context.Context, p []byte) (n int, err error) {
+	if t.n <= 0 {
+		return len(p), nil
+	}
+	// real write
+	n = len(p)
+	if int64(n) > t.n {
+		n = int(t.n)
+	}
+	n, err = t.w.WriteContext(ctx, p[0:n])
+	t.n -= int64(n)
+	if err == nil {
+		n = len(p)
+	}
+	return
+}
+
+// ShortWriter returns a Writer that writes at most n bytes of each Write to w,
+// and reports the short count without an error.
+// It violates the Writer contract on purpose, to check that callers
+// detect short writes.
+func ShortWriter(w ctxio.Writer, n int) ctxio.Writer {
+	return &shortWriter{w, n}
+}
+
+type shortWriter struct {
+	w ctxio.Writer
+	n int
+}
+
+func (s *shortWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	if len(p) > s.n {
+		p = p[:s.n]
+	}
+	return s.w.WriteContext(ctx, p)
+}
+
+// ErrWriter returns a Writer that returns 0, err from all Write calls.
+func ErrWriter(err error) ctxio.Writer {
+	return &errWriter{err: err}
+}
+
+type errWriter struct {
+	err error
+}
+
+func (w *errWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	return 0, w.err
+}
+
+// BlockingWriter returns a Writer that blocks until ctx is done,
+// and returns 0, ctx.Err() from all Write calls.
+func BlockingWriter() ctxio.Writer {
+	return blockingWriter{}
+}
+
+type blockingWriter struct{}
+
+func (blockingWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+// TestWriterCancel tests that a write to w, which must not accept any data,
+// returns an error wrapping the cause of the cancellation,
+// both if the context is done before the call and during the call.
+func TestWriterCancel(w ctxio.Writer) error {
+	return testCancel("Write", func(ctx context.Context) (int, error) {
+		return w.WriteContext(ctx, []byte("hello"))
+	})
+}
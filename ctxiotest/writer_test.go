@@ -0,0 +1,63 @@
+package ctxiotest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/shogo82148/ctxio"
+)
+
+func TestTruncateWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := TruncateWriter(ctxio.NewWriter(&b), 7)
+	for _, s := range []string{"hello, ", "world"} {
+		n, err := w.WriteContext(context.Background(), []byte(s))
+		if n != len(s) || err != nil {
+			t.Errorf("Write(%q) = %d, %v, want %d, nil", s, n, err, len(s))
+		}
+	}
+	if b.String() != "hello, " {
+		t.Errorf("want %q, got %q", "hello, ", b.String())
+	}
+}
+
+func TestShortWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := ShortWriter(ctxio.NewWriter(&b), 3)
+	n, err := w.WriteContext(context.Background(), []byte("hello"))
+	if n != 3 || err != nil {
+		t.Errorf("Write = %d, %v, want 3, nil", n, err)
+	}
+	if b.String() != "hel" {
+		t.Errorf("want %q, got %q", "hel", b.String())
+	}
+
+	// Copy detects the short write.
+	src := ctxio.NewReader(bytes.NewReader([]byte("hello")))
+	if _, err := ctxio.Copy(context.Background(), w, src); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("want io.ErrShortWrite, got %v", err)
+	}
+}
+
+func TestErrWriter(t *testing.T) {
+	errTest := errors.New("test error")
+	w := ErrWriter(errTest)
+	if n, err := w.WriteContext(context.Background(), []byte("hello")); n != 0 || err != errTest {
+		t.Errorf("Write = %d, %v, want 0, %v", n, err, errTest)
+	}
+}
+
+func TestBlockingWriter(t *testing.T) {
+	if err := TestWriterCancel(BlockingWriter()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTestWriterCancel_Misbehaving(t *testing.T) {
+	if err := TestWriterCancel(ctxio.Discard); err == nil {
+		t.Error("want error, got nil")
+	}
+}
@@ -0,0 +1,99 @@
+package ctxio
+
+import (
+	"io"
+	"strconv"
+)
+
+// Operations reported in OpError.Op.
+const (
+	OpRead  = "read"
+	OpWrite = "write"
+	OpCopy  = "copy"
+)
+
+// Sides reported in OpError.Side.
+const (
+	SideSrc = "src"
+	SideDst = "dst"
+)
+
+// OpError is the error type usually returned by functions in the ctxio
+// package. It describes the operation, the side of the transfer that
+// failed, and how many bytes got through before the error.
+type OpError struct {
+	// Op is the operation which caused the error, such as
+	// "read", "write" or "copy".
+	Op string
+
+	// Side is the side of a transfer that failed, "src" or "dst".
+	// It is empty if the side is not known.
+	Side string
+
+	// N is the number of bytes transferred before the error.
+	N int64
+
+	// Err is the error that occurred during the operation.
+	Err error
+}
+
+func (e *OpError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	s := "ctxio: " + e.Op
+	if e.Side != "" {
+		s += " " + e.Side
+	}
+	s += " after " + strconv.FormatInt(e.N, 10) + " bytes"
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the underlying error is a timeout.
+func (e *OpError) Timeout() bool {
+	t, ok := e.Err.(interface{ Timeout() bool })
+	return ok && t.Timeout()
+}
+
+// wrapError wraps err in an OpError.
+// nil and io.EOF are returned as is, because callers compare them by ==.
+func wrapError(op, side string, n int64, err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return &OpError{Op: op, Side: side, N: n, Err: err}
+}
+
+// rewrapError is like wrapError, but if err is an *OpError, such as one returned by an adapter,
+// it returns a copy of err with op, side and n replaced instead of nesting it.
+func rewrapError(op, side string, n int64, err error) error {
+	if e, ok := err.(*OpError); ok {
+		return &OpError{Op: op, Side: side, N: n, Err: e.Err}
+	}
+	return wrapError(op, side, n, err)
+}
+
+// copyError wraps an error returned by the WriterTo or ReaderFrom fast path of Copy.
+// The side is taken from the error returned by the adapter if it is known.
+func copyError(n int64, err error) error {
+	side := ""
+	if e, ok := err.(*OpError); ok {
+		switch e.Op {
+		case OpRead:
+			side = SideSrc
+		case OpWrite:
+			side = SideDst
+		case OpCopy:
+			// the fast path already reported the copy.
+			return err
+		}
+	}
+	return rewrapError(OpCopy, side, n, err)
+}
@@ -0,0 +1,189 @@
+package ctxio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+var errTest = errors.New("test error")
+
+type errReader struct {
+	data []byte
+	err  error
+}
+
+func (r *errReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(data, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+type errWriter struct {
+	limit int
+	err   error
+}
+
+func (w *errWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	if len(data) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, w.err
+	}
+	w.limit -= len(data)
+	return len(data), nil
+}
+
+func TestCopy_OpErrorSrc(t *testing.T) {
+	src := &errReader{data: []byte("hello"), err: errTest}
+	n, err := Copy(context.Background(), Discard, onlyReader{src})
+	if n != 5 {
+		t.Errorf("want 5, got %d", n)
+	}
+	if !errors.Is(err, errTest) {
+		t.Fatalf("want errTest, got %v", err)
+	}
+	var opErr *OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("want *OpError, got %T", err)
+	}
+	if opErr.Op != OpCopy || opErr.Side != SideSrc || opErr.N != 5 {
+		t.Errorf("unexpected error: %#v", opErr)
+	}
+}
+
+func TestCopy_OpErrorDst(t *testing.T) {
+	src := &errReader{data: []byte("hello, world"), err: io.EOF}
+	dst := &errWriter{limit: 5, err: errTest}
+	_, err := CopyBuffer(context.Background(), dst, src, make([]byte, 1))
+	if !errors.Is(err, errTest) {
+		t.Fatalf("want errTest, got %v", err)
+	}
+	var opErr *OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("want *OpError, got %T", err)
+	}
+	if opErr.Op != OpCopy || opErr.Side != SideDst {
+		t.Errorf("unexpected error: %#v", opErr)
+	}
+}
+
+func TestCopy_OpErrorClosedAdapter(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	r := NewReader(pr)
+	r.Close()
+
+	_, err := Copy(context.Background(), Discard, r)
+	if !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("want fs.ErrClosed, got %v", err)
+	}
+	var opErr *OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("want *OpError, got %T", err)
+	}
+	if opErr.Op != OpCopy || opErr.Side != SideSrc {
+		t.Errorf("unexpected error: %#v", opErr)
+	}
+	// the error of the adapter is not nested.
+	if opErr.Err != fs.ErrClosed {
+		t.Errorf("want fs.ErrClosed unwrapped once, got %#v", opErr.Err)
+	}
+}
+
+func TestCopy_OpErrorAdapter(t *testing.T) {
+	// the adapter returns an *OpError with the bytes of the last read.
+	for _, tt := range []struct {
+		name string
+		copy func(ctx context.Context, r Reader) (int64, error)
+	}{
+		{"Discard", func(ctx context.Context, r Reader) (int64, error) {
+			return CopyBuffer(ctx, Discard, r, nil)
+		}},
+		{"slow path", func(ctx context.Context, r Reader) (int64, error) {
+			return CopyBuffer(ctx, writerOnly{Discard}, r, make([]byte, 2))
+		}},
+		{"CopyReadAhead", func(ctx context.Context, r Reader) (int64, error) {
+			return CopyReadAhead(ctx, Discard, r, nil)
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, pw := io.Pipe()
+			go func() {
+				pw.Write([]byte("hello"))
+				pw.CloseWithError(errTest)
+			}()
+			r := NewReader(pr)
+			defer r.Close()
+
+			n, err := tt.copy(context.Background(), onlyReader{r})
+			if n != 5 {
+				t.Errorf("want 5, got %d", n)
+			}
+			var opErr *OpError
+			if !errors.As(err, &opErr) {
+				t.Fatalf("want *OpError, got %T", err)
+			}
+			if opErr.Op != OpCopy || opErr.Side != SideSrc || opErr.N != 5 || opErr.Err != errTest {
+				t.Errorf("unexpected error: %#v", opErr)
+			}
+			if want := "ctxio: copy src after 5 bytes: test error"; err.Error() != want {
+				t.Errorf("want %q, got %q", want, err.Error())
+			}
+		})
+	}
+}
+
+// copyingReader is a WriterTo whose fast path fails like a nested Copy.
+type copyingReader struct {
+	err error
+}
+
+func (r copyingReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (r copyingReader) WriteToContext(ctx context.Context, w Writer) (int64, error) {
+	return 3, r.err
+}
+
+func TestCopy_OpErrorNested(t *testing.T) {
+	want := &OpError{Op: OpCopy, Side: SideDst, N: 3, Err: errTest}
+	_, err := Copy(context.Background(), Discard, copyingReader{want})
+	if err != want {
+		t.Errorf("want the error of the nested copy as is, got %#v", err)
+	}
+}
+
+func TestReadAll_OpError(t *testing.T) {
+	src := &errReader{data: []byte("hello"), err: errTest}
+	data, err := ReadAll(context.Background(), src)
+	if string(data) != "hello" {
+		t.Errorf("want %q, got %q", "hello", data)
+	}
+	var opErr *OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("want *OpError, got %T", err)
+	}
+	if opErr.Op != OpRead || opErr.N != 5 || opErr.Err != errTest {
+		t.Errorf("unexpected error: %#v", opErr)
+	}
+}
+
+func TestOpError_Error(t *testing.T) {
+	err := &OpError{Op: OpCopy, Side: SideDst, N: 42, Err: errTest}
+	want := "ctxio: copy dst after 42 bytes: test error"
+	if got := err.Error(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+// onlyReader hides the optional interfaces of Reader.
+type onlyReader struct {
+	Reader
+}
@@ -0,0 +1,7 @@
+package ctxio
+
+// WriteBufferSize is the size of the chunks written by the writers returned by NewWriter.
+const WriteBufferSize = writeBufferSize
+
+// RetryBackoff returns the delay before the given attempt of p.
+var RetryBackoff = (*RetryPolicy).backoff
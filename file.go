@@ -0,0 +1,184 @@
+package ctxio
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ReadFile reads the named file and returns the contents.
+// It is like os.ReadFile, but the read is canceled when ctx is done.
+// A successful call returns err == nil, not err == io.EOF.
+func ReadFile(ctx context.Context, name string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := NewReader(f)
+	defer r.Close()
+	return ReadAll(ctx, r)
+}
+
+// WriteFile writes data to the named file, creating it if necessary.
+// Unlike os.WriteFile, the file is replaced atomically by an AtomicFile:
+// if ctx is done or an error occurs, the named file is left untouched.
+// The permissions of a new file are perm (before umask),
+// even if the named file already exists.
+func WriteFile(ctx context.Context, name string, data []byte, perm fs.FileMode) error {
+	f, err := CreateAtomic(name, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	if _, err := f.WriteContext(ctx, data); err != nil {
+		return err
+	}
+	return f.CommitContext(ctx)
+}
+
+// ErrCommitted is returned by the operations of an AtomicFile after CommitContext or Abort.
+// It is returned as is, not wrapped in an *OpError.
+var ErrCommitted = errors.New("ctxio: atomic file already committed or aborted")
+
+// AtomicFile is a Writer that replaces the named file atomically.
+// The data is written to a temporary file in the same directory,
+// which is renamed to the named file by CommitContext,
+// or removed by Abort.
+//
+// Readers of the named file see either the old contents or the new ones,
+// never a partially written file.
+// An AtomicFile is not safe for concurrent use.
+type AtomicFile struct {
+	name string
+	f    *os.File
+	done bool
+
+	// err is the first error of WriteContext, which fails CommitContext.
+	err error
+}
+
+var _ Writer = (*AtomicFile)(nil)
+
+// CreateAtomic creates a temporary file for replacing the named file.
+// The permissions of the file are perm (before umask).
+func CreateAtomic(name string, perm fs.FileMode) (*AtomicFile, error) {
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	for i := 0; ; i++ {
+		// os.CreateTemp always uses 0600, so open the file by ourselves to apply perm.
+		tmp := filepath.Join(dir, "."+base+".tmp"+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, fs.ErrExist) && i < 10000 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &AtomicFile{
+			name: name,
+			f:    f,
+		}, nil
+	}
+}
+
+// Name returns the name of the file to be replaced.
+func (f *AtomicFile) Name() string {
+	return f.name
+}
+
+// WriteContext writes to the temporary file.
+// The data is written in chunks, and ctx is checked between them;
+// a write to the file in progress is not interrupted.
+// If WriteContext fails, the following writes and CommitContext fail with the same error.
+func (f *AtomicFile) WriteContext(ctx context.Context, data []byte) (n int, err error) {
+	if f.done {
+		return 0, ErrCommitted
+	}
+	if f.err != nil {
+		return 0, f.err
+	}
+	for n < len(data) {
+		if err := ctx.Err(); err != nil {
+			f.err = wrapError(OpWrite, "", int64(n), err)
+			return n, f.err
+		}
+		end := n + writeBufferSize
+		if end > len(data) {
+			end = len(data)
+		}
+		m, err := f.f.Write(data[n:end])
+		n += m
+		if err != nil {
+			f.err = wrapError(OpWrite, "", int64(n), err)
+			return n, f.err
+		}
+	}
+	return n, nil
+}
+
+// CommitContext flushes the temporary file to the storage, and renames it to the named file.
+// If ctx is done before the rename, or a previous WriteContext failed,
+// the temporary file is removed, and the named file is left untouched.
+// Flushing the file is not interrupted by ctx.
+func (f *AtomicFile) CommitContext(ctx context.Context) error {
+	if f.done {
+		return ErrCommitted
+	}
+	if f.err != nil {
+		f.Abort()
+		return f.err
+	}
+	if err := ctx.Err(); err != nil {
+		f.Abort()
+		return err
+	}
+
+	f.done = true
+	err := f.f.Sync()
+	if cerr := f.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = os.Rename(f.f.Name(), f.name)
+	}
+	if err != nil {
+		os.Remove(f.f.Name())
+		return err
+	}
+
+	// make the rename durable. Some platforms don't support syncing directories.
+	if d, err := os.Open(filepath.Dir(f.name)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// Abort removes the temporary file, and leaves the named file untouched.
+// Abort after CommitContext does nothing, so it can be deferred.
+func (f *AtomicFile) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	err := f.f.Close()
+	if rerr := os.Remove(f.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package ctxio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file")
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	if err := os.WriteFile(name, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadFile(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("content mismatch")
+	}
+
+	if _, err := ReadFile(context.Background(), name+".missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want os.ErrNotExist, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ReadFile(ctx, name); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file")
+	if err := WriteFile(context.Background(), name, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("want %q, got %q", "hello", got)
+	}
+	if runtime.GOOS != "windows" {
+		st, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Mode().Perm() != 0o600 {
+			t.Errorf("want 0600, got %v", st.Mode().Perm())
+		}
+	}
+
+	// a canceled write leaves the file untouched.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WriteFile(ctx, name, []byte("world"), 0o600); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	got, err = os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("want %q, got %q", "hello", got)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestAtomicFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file")
+	if err := os.WriteFile(name, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := CreateAtomic(name, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Abort()
+	if _, err := f.WriteContext(ctx, []byte("new contents")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is not replaced until committed.
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "old" {
+		t.Errorf("want %q, got %q", "old", got)
+	}
+
+	if err := f.CommitContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got, err = os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "new contents" {
+		t.Errorf("want %q, got %q", "new contents", got)
+	}
+
+	if _, err := f.WriteContext(ctx, []byte("more")); err != ErrCommitted {
+		t.Errorf("want ErrCommitted, got %v", err)
+	}
+	if err := f.CommitContext(ctx); err != ErrCommitted {
+		t.Errorf("want ErrCommitted, got %v", err)
+	}
+	if err := f.Abort(); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestAtomicFile_Abort(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file")
+
+	f, err := CreateAtomic(name, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteContext(ctx, []byte("new contents")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want os.ErrNotExist, got %v", err)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestAtomicFile_CommitCanceled(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file")
+
+	f, err := CreateAtomic(name, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteContext(context.Background(), []byte("new contents")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.CommitContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want os.ErrNotExist, got %v", err)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestAtomicFile_WriteCanceled(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file")
+
+	f, err := CreateAtomic(name, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Abort()
+
+	// ctx is canceled after the first chunk is written.
+	ctx := &cancelAfterContext{Context: context.Background(), checks: 1}
+	n, err := f.WriteContext(ctx, make([]byte, 3*writeBufferSize))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if n != writeBufferSize {
+		t.Errorf("want %d, got %d", writeBufferSize, n)
+	}
+
+	// the partially written file must not be committed.
+	if err := f.CommitContext(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want os.ErrNotExist, got %v", err)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+// cancelAfterContext reports that it is canceled after Err is called checks times.
+type cancelAfterContext struct {
+	context.Context
+	checks int
+}
+
+func (c *cancelAfterContext) Err() error {
+	if c.checks <= 0 {
+		return context.Canceled
+	}
+	c.checks--
+	return nil
+}
+
+// assertNoTempFiles checks that dir contains only the target file.
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "file" {
+			t.Errorf("unexpected file %q", e.Name())
+		}
+	}
+}
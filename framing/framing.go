@@ -0,0 +1,114 @@
+// Package framing splits ctxio streams into messages.
+//
+// It supports frames prefixed by their length, encoded in a fixed-width big-endian integer
+// or in an unsigned varint, and frames terminated by a delimiter byte.
+package framing
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxFrameSize is the maximum frame size used when the maximum is not positive.
+const DefaultMaxFrameSize = 4 << 20
+
+// Prefix is the encoding of the length prefix of frames.
+type Prefix int
+
+const (
+	// Uint8 is a 1-byte length prefix.
+	Uint8 Prefix = 1
+
+	// Uint16 is a 2-byte big-endian length prefix.
+	Uint16 Prefix = 2
+
+	// Uint32 is a 4-byte big-endian length prefix.
+	Uint32 Prefix = 4
+
+	// Uint64 is a 8-byte big-endian length prefix.
+	Uint64 Prefix = 8
+
+	// Uvarint is a length prefix encoded in an unsigned varint.
+	Uvarint Prefix = -1
+)
+
+func (p Prefix) String() string {
+	switch p {
+	case Uint8:
+		return "Uint8"
+	case Uint16:
+		return "Uint16"
+	case Uint32:
+		return "Uint32"
+	case Uint64:
+		return "Uint64"
+	case Uvarint:
+		return "Uvarint"
+	}
+	return fmt.Sprintf("Prefix(%d)", int(p))
+}
+
+// maxSize returns the maximum size that the prefix can represent.
+func (p Prefix) maxSize() uint64 {
+	switch p {
+	case Uint8:
+		return 1<<8 - 1
+	case Uint16:
+		return 1<<16 - 1
+	case Uint32:
+		return 1<<32 - 1
+	}
+	return 1<<64 - 1
+}
+
+// appendSize appends the length prefix of a frame of size n.
+func (p Prefix) appendSize(buf []byte, n uint64) []byte {
+	switch p {
+	case Uint8:
+		return append(buf, byte(n))
+	case Uint16:
+		return append(buf, byte(n>>8), byte(n))
+	case Uint32:
+		return append(buf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	case Uint64:
+		return append(buf, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32), byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	var tmp [binary.MaxVarintLen64]byte
+	m := binary.PutUvarint(tmp[:], n)
+	return append(buf, tmp[:m]...)
+}
+
+func (p Prefix) valid() bool {
+	switch p {
+	case Uint8, Uint16, Uint32, Uint64, Uvarint:
+		return true
+	}
+	return false
+}
+
+// ErrDelimiter is returned by WriteFrameContext when the frame contains the delimiter.
+var ErrDelimiter = errors.New("framing: frame contains the delimiter")
+
+// FrameTooLargeError is returned when a frame exceeds the maximum frame size.
+type FrameTooLargeError struct {
+	// Size is the size of the frame.
+	// For delimited frames, it is the number of bytes read before giving up.
+	Size uint64
+
+	// Max is the maximum frame size.
+	Max uint64
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("framing: frame size %d exceeds the maximum %d", e.Size, e.Max)
+}
+
+// CorruptFrameError is returned when the stream contains a broken frame.
+type CorruptFrameError struct {
+	Reason string
+}
+
+func (e *CorruptFrameError) Error() string {
+	return "framing: corrupt frame: " + e.Reason
+}
@@ -0,0 +1,298 @@
+package framing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+)
+
+func TestLengthPrefixed(t *testing.T) {
+	ctx := context.Background()
+	frames := []string{"hello", "", "world", strings.Repeat("x", 300)}
+	for _, prefix := range []Prefix{Uint16, Uint32, Uint64, Uvarint} {
+		t.Run(prefix.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			fw := NewFrameWriter(ctxio.NewWriter(&buf), prefix, 0)
+			for _, f := range frames {
+				if err := fw.WriteFrameContext(ctx, []byte(f)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fr := NewFrameReader(ctxio.NewReader(&buf), prefix, 0)
+			for _, f := range frames {
+				got, err := fr.NextFrameContext(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != f {
+					t.Errorf("want %q, got %q", f, got)
+				}
+				ctxio.BufferPoolFromContext(ctx).Put(got)
+			}
+			if _, err := fr.NextFrameContext(ctx); err != io.EOF {
+				t.Errorf("want io.EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBufferPool(t *testing.T) {
+	pool := &countingPool{}
+	ctx := ctxio.WithBufferPool(context.Background(), pool)
+
+	var buf bytes.Buffer
+	fw := NewFrameWriter(ctxio.NewWriter(&buf), Uvarint, 0)
+	if err := fw.WriteFrameContext(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	fr := NewFrameReader(ctxio.NewReader(&buf), Uvarint, 0)
+	frame, err := fr.NextFrameContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(frame) != "hello" {
+		t.Errorf("want %q, got %q", "hello", frame)
+	}
+	if pool.gets != 2 || pool.puts != 1 {
+		t.Errorf("want 2 gets and 1 put, got %d and %d", pool.gets, pool.puts)
+	}
+}
+
+// countingPool is a ctxio.BufferPool that counts the calls.
+type countingPool struct {
+	gets, puts int
+}
+
+func (p *countingPool) Get(n int) []byte {
+	p.gets++
+	return make([]byte, n)
+}
+
+func (p *countingPool) Put(b []byte) {
+	p.puts++
+}
+
+func TestPrefixEncoding(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		prefix Prefix
+		want   []byte
+	}{
+		{Uint8, []byte{0x03, 'a', 'b', 'c'}},
+		{Uint16, []byte{0x00, 0x03, 'a', 'b', 'c'}},
+		{Uint32, []byte{0x00, 0x00, 0x00, 0x03, 'a', 'b', 'c'}},
+		{Uint64, []byte{0, 0, 0, 0, 0, 0, 0, 0x03, 'a', 'b', 'c'}},
+		{Uvarint, []byte{0x03, 'a', 'b', 'c'}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		fw := NewFrameWriter(ctxio.NewWriter(&buf), tt.prefix, 0)
+		if err := fw.WriteFrameContext(ctx, []byte("abc")); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("%v: want %x, got %x", tt.prefix, tt.want, buf.Bytes())
+		}
+	}
+}
+
+func TestDelimited(t *testing.T) {
+	ctx := context.Background()
+	in := "hello\nworld\n\nlast\n"
+	fr := NewDelimitedFrameReader(ctxio.NewReader(strings.NewReader(in)), '\n', 0)
+	buf := make([]byte, 16)
+	for _, want := range []string{"hello", "world", "", "last"} {
+		n, err := fr.ReadFrameContext(ctx, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != want {
+			t.Errorf("want %q, got %q", want, buf[:n])
+		}
+	}
+	if _, err := fr.ReadFrameContext(ctx, buf); err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+
+	var out bytes.Buffer
+	fw := NewDelimitedFrameWriter(ctxio.NewWriter(&out), '\n', 0)
+	for _, f := range []string{"hello", "world", "", "last"} {
+		if err := fw.WriteFrameContext(ctx, []byte(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.String() != in {
+		t.Errorf("want %q, got %q", in, out.String())
+	}
+	if err := fw.WriteFrameContext(ctx, []byte("a\nb")); err != ErrDelimiter {
+		t.Errorf("want ErrDelimiter, got %v", err)
+	}
+}
+
+func TestDelimited_Unterminated(t *testing.T) {
+	fr := NewDelimitedFrameReader(ctxio.NewReader(strings.NewReader("hello")), '\n', 0)
+	if _, err := fr.NextFrameContext(context.Background()); err != io.ErrUnexpectedEOF {
+		t.Errorf("want io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestDelimited_ReadError(t *testing.T) {
+	ctx := context.Background()
+	errTest := errors.New("test")
+	fr := NewDelimitedFrameReader(&dataErrReader{
+		data: []string{"hello\nwor", "ld\n"},
+		err:  errTest,
+	}, '\n', 0)
+
+	frame, err := fr.NextFrameContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(frame) != "hello" {
+		t.Errorf("want %q, got %q", "hello", frame)
+	}
+
+	// the error returned with the data is reported after the buffered frames.
+	if _, err := fr.NextFrameContext(ctx); err != errTest {
+		t.Errorf("want errTest, got %v", err)
+	}
+
+	// the partial frame is kept.
+	frame, err = fr.NextFrameContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(frame) != "world" {
+		t.Errorf("want %q, got %q", "world", frame)
+	}
+}
+
+// dataErrReader returns each of data with err, and then io.EOF.
+type dataErrReader struct {
+	data []string
+	err  error
+}
+
+func (r *dataErrReader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[0])
+	r.data = r.data[1:]
+	return n, r.err
+}
+
+func TestReadFrameContext_ShortBuffer(t *testing.T) {
+	ctx := context.Background()
+	for _, delimited := range []bool{false, true} {
+		var buf bytes.Buffer
+		var fw *FrameWriter
+		var fr *FrameReader
+		if delimited {
+			fw = NewDelimitedFrameWriter(ctxio.NewWriter(&buf), 0, 0)
+			fr = NewDelimitedFrameReader(ctxio.NewReader(&buf), 0, 0)
+		} else {
+			fw = NewFrameWriter(ctxio.NewWriter(&buf), Uint32, 0)
+			fr = NewFrameReader(ctxio.NewReader(&buf), Uint32, 0)
+		}
+		if err := fw.WriteFrameContext(ctx, []byte("hello, world")); err != nil {
+			t.Fatal(err)
+		}
+
+		small := make([]byte, 4)
+		if _, err := fr.ReadFrameContext(ctx, small); err != io.ErrShortBuffer {
+			t.Fatalf("want io.ErrShortBuffer, got %v", err)
+		}
+		size, err := fr.FrameSizeContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != 12 {
+			t.Errorf("want 12, got %d", size)
+		}
+		large := make([]byte, size)
+		n, err := fr.ReadFrameContext(ctx, large)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(large[:n]) != "hello, world" {
+			t.Errorf("want %q, got %q", "hello, world", large[:n])
+		}
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	fw := NewFrameWriter(ctxio.NewWriter(&buf), Uint32, 0)
+	if err := fw.WriteFrameContext(ctx, make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	fr := NewFrameReader(ctxio.NewReader(&buf), Uint32, 10)
+	_, err := fr.NextFrameContext(ctx)
+	var tooLarge *FrameTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("want *FrameTooLargeError, got %v", err)
+	}
+	if tooLarge.Size != 100 || tooLarge.Max != 10 {
+		t.Errorf("unexpected error: %v", tooLarge)
+	}
+
+	// the error is sticky.
+	if _, err := fr.NextFrameContext(ctx); err != tooLarge {
+		t.Errorf("want %v, got %v", tooLarge, err)
+	}
+
+	// the writer also checks the size.
+	fw = NewFrameWriter(ctxio.Discard, Uint8, 0)
+	if err := fw.WriteFrameContext(ctx, make([]byte, 256)); !errors.As(err, &tooLarge) {
+		t.Errorf("want *FrameTooLargeError, got %v", err)
+	}
+
+	fr = NewDelimitedFrameReader(ctxio.NewReader(strings.NewReader(strings.Repeat("x", 100)+"\n")), '\n', 10)
+	if _, err := fr.NextFrameContext(ctx); !errors.As(err, &tooLarge) {
+		t.Errorf("want *FrameTooLargeError, got %v", err)
+	}
+}
+
+func TestCorruptFrame(t *testing.T) {
+	in := bytes.Repeat([]byte{0xff}, 11)
+	fr := NewFrameReader(ctxio.NewReader(bytes.NewReader(in)), Uvarint, 0)
+	_, err := fr.NextFrameContext(context.Background())
+	var corrupt *CorruptFrameError
+	if !errors.As(err, &corrupt) {
+		t.Errorf("want *CorruptFrameError, got %v", err)
+	}
+}
+
+func TestFrameReader_Cancel(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+	fr := NewFrameReader(pr, Uint32, 0)
+	fw := NewFrameWriter(pw, Uint32, 0)
+
+	// canceling while waiting for a frame doesn't break the stream.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := fr.NextFrameContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+
+	go fw.WriteFrameContext(context.Background(), []byte("hello"))
+	got, err := fr.NextFrameContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("want %q, got %q", "hello", got)
+	}
+}
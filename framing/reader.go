@@ -0,0 +1,258 @@
+package framing
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// FrameReader reads frames from a ctxio.Reader.
+//
+// If a read fails in the middle of a frame, the stream can't be resynchronized,
+// and all subsequent reads return the same error.
+// A context canceled while waiting for a new frame doesn't break the stream.
+type FrameReader struct {
+	r         ctxio.Reader
+	prefix    Prefix
+	delim     byte
+	delimited bool
+	max       uint64
+	err       error
+
+	// pending is the size of the frame whose header has been read,
+	// or -1 if there is no such frame.
+	pending int
+
+	// read-ahead buffer for delimited frames.
+	buf        []byte
+	start, end int
+
+	// readErr is the error of the read that returned data for the buffer.
+	// It is returned after the frames in the buffer.
+	readErr error
+}
+
+// NewFrameReader returns a FrameReader that reads frames with the length prefix.
+// Frames larger than max bytes are rejected. If max is not positive, DefaultMaxFrameSize is used.
+func NewFrameReader(r ctxio.Reader, prefix Prefix, max int) *FrameReader {
+	if !prefix.valid() {
+		panic("framing: invalid prefix " + prefix.String())
+	}
+	return &FrameReader{
+		r:       r,
+		prefix:  prefix,
+		max:     maxSize(max),
+		pending: -1,
+	}
+}
+
+// NewDelimitedFrameReader returns a FrameReader that reads frames terminated by delim.
+// The delimiter is not included in the frames.
+// Frames larger than max bytes are rejected. If max is not positive, DefaultMaxFrameSize is used.
+func NewDelimitedFrameReader(r ctxio.Reader, delim byte, max int) *FrameReader {
+	return &FrameReader{
+		r:         r,
+		delim:     delim,
+		delimited: true,
+		max:       maxSize(max),
+		pending:   -1,
+	}
+}
+
+func maxSize(max int) uint64 {
+	if max <= 0 {
+		return DefaultMaxFrameSize
+	}
+	return uint64(max)
+}
+
+// ReadFrameContext reads the next frame into buf, and returns the size of the frame.
+// It returns io.EOF if the stream ends at a frame boundary.
+//
+// If buf is too small for the frame, ReadFrameContext returns io.ErrShortBuffer
+// without consuming the frame, so the caller can retry with a larger buffer.
+// FrameSize reports the size needed.
+func (fr *FrameReader) ReadFrameContext(ctx context.Context, buf []byte) (int, error) {
+	size, err := fr.frameSize(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if size > len(buf) {
+		return 0, io.ErrShortBuffer
+	}
+	if err := fr.readBody(ctx, buf[:size]); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// FrameSizeContext returns the size of the next frame without consuming it.
+func (fr *FrameReader) FrameSizeContext(ctx context.Context) (int, error) {
+	return fr.frameSize(ctx)
+}
+
+// NextFrameContext reads the next frame into a buffer taken from the pool carried by ctx
+// (see ctxio.BufferPoolFromContext).
+// The caller may return the buffer to the pool after using it.
+// It returns io.EOF if the stream ends at a frame boundary.
+func (fr *FrameReader) NextFrameContext(ctx context.Context) ([]byte, error) {
+	size, err := fr.frameSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pool := ctxio.BufferPoolFromContext(ctx)
+	buf := pool.Get(size)
+	if err := fr.readBody(ctx, buf); err != nil {
+		pool.Put(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// frameSize returns the size of the next frame.
+func (fr *FrameReader) frameSize(ctx context.Context) (int, error) {
+	if fr.err != nil {
+		return 0, fr.err
+	}
+	if fr.delimited {
+		return fr.delimitedSize(ctx)
+	}
+	if fr.pending >= 0 {
+		return fr.pending, nil
+	}
+
+	var size uint64
+	var n int
+	var err error
+	if fr.prefix == Uvarint {
+		size, n, err = fr.readUvarint(ctx)
+	} else {
+		var hdr [8]byte
+		n, err = ctxio.ReadFull(ctx, fr.r, hdr[:fr.prefix])
+		for _, b := range hdr[:n] {
+			size = size<<8 | uint64(b)
+		}
+	}
+	if err != nil {
+		if n > 0 {
+			// the stream is broken in the middle of a header.
+			fr.err = err
+		}
+		return 0, err
+	}
+	if size > fr.max {
+		fr.err = &FrameTooLargeError{Size: size, Max: fr.max}
+		return 0, fr.err
+	}
+	fr.pending = int(size)
+	return fr.pending, nil
+}
+
+// readUvarint reads an uvarint length prefix.
+// It returns the number of bytes read.
+func (fr *FrameReader) readUvarint(ctx context.Context) (uint64, int, error) {
+	var x uint64
+	var s uint
+	var b [1]byte
+	for i := 0; i < 10; i++ {
+		if _, err := ctxio.ReadFull(ctx, fr.r, b[:]); err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, i, err
+		}
+		if b[0] < 0x80 {
+			if i == 9 && b[0] > 1 {
+				return 0, i + 1, &CorruptFrameError{Reason: "length prefix overflows a 64-bit integer"}
+			}
+			return x | uint64(b[0])<<s, i + 1, nil
+		}
+		x |= uint64(b[0]&0x7f) << s
+		s += 7
+	}
+	return 0, 10, &CorruptFrameError{Reason: "length prefix overflows a 64-bit integer"}
+}
+
+// delimitedSize buffers the next delimited frame, and returns its size.
+func (fr *FrameReader) delimitedSize(ctx context.Context) (int, error) {
+	scanned := 0
+	for {
+		if i := bytes.IndexByte(fr.buf[fr.start+scanned:fr.end], fr.delim); i >= 0 {
+			fr.pending = scanned + i
+			return fr.pending, nil
+		}
+		scanned = fr.end - fr.start
+		if uint64(scanned) > fr.max {
+			fr.err = &FrameTooLargeError{Size: uint64(scanned), Max: fr.max}
+			return 0, fr.err
+		}
+		if err := fr.readErr; err != nil {
+			fr.readErr = nil
+			return 0, err
+		}
+
+		// make room for reading more data.
+		if fr.start > 0 {
+			copy(fr.buf, fr.buf[fr.start:fr.end])
+			fr.end -= fr.start
+			fr.start = 0
+		}
+		if fr.end == len(fr.buf) {
+			size := 2 * len(fr.buf)
+			if size < 512 {
+				size = 512
+			}
+			if limit := fr.max + 1; uint64(size) > limit {
+				size = int(limit)
+			}
+			buf := make([]byte, size)
+			copy(buf, fr.buf[:fr.end])
+			fr.buf = buf
+		}
+
+		n, err := fr.r.ReadContext(ctx, fr.buf[fr.end:])
+		fr.end += n
+		if err != nil {
+			if err == io.EOF && fr.end > fr.start {
+				// the last frame is not terminated.
+				if i := bytes.IndexByte(fr.buf[fr.start:fr.end], fr.delim); i >= 0 {
+					continue
+				}
+				fr.err = io.ErrUnexpectedEOF
+				return 0, fr.err
+			}
+			if n > 0 {
+				// return the error after the frames already read.
+				fr.readErr = err
+				continue
+			}
+			return 0, err
+		}
+	}
+}
+
+// readBody reads the body of the frame whose size is returned by frameSize.
+func (fr *FrameReader) readBody(ctx context.Context, buf []byte) error {
+	if fr.delimited {
+		copy(buf, fr.buf[fr.start:])
+		fr.start += fr.pending + 1 // skip the delimiter
+		fr.pending = -1
+		return nil
+	}
+
+	n, err := ctxio.ReadFull(ctx, fr.r, buf)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if n > 0 || err == io.ErrUnexpectedEOF {
+			// the stream is broken in the middle of a body.
+			fr.err = err
+		}
+		return err
+	}
+	fr.pending = -1
+	return nil
+}
@@ -0,0 +1,73 @@
+package framing
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// FrameWriter writes frames to a ctxio.Writer.
+// Each frame is written by a single call of WriteContext.
+type FrameWriter struct {
+	w         ctxio.Writer
+	prefix    Prefix
+	delim     byte
+	delimited bool
+	max       uint64
+}
+
+// NewFrameWriter returns a FrameWriter that writes frames with the length prefix.
+// Frames larger than max bytes are rejected. If max is not positive, DefaultMaxFrameSize is used.
+func NewFrameWriter(w ctxio.Writer, prefix Prefix, max int) *FrameWriter {
+	if !prefix.valid() {
+		panic("framing: invalid prefix " + prefix.String())
+	}
+	m := maxSize(max)
+	if limit := prefix.maxSize(); m > limit {
+		m = limit
+	}
+	return &FrameWriter{
+		w:      w,
+		prefix: prefix,
+		max:    m,
+	}
+}
+
+// NewDelimitedFrameWriter returns a FrameWriter that writes frames terminated by delim.
+// Frames larger than max bytes are rejected. If max is not positive, DefaultMaxFrameSize is used.
+func NewDelimitedFrameWriter(w ctxio.Writer, delim byte, max int) *FrameWriter {
+	return &FrameWriter{
+		w:         w,
+		delim:     delim,
+		delimited: true,
+		max:       maxSize(max),
+	}
+}
+
+// WriteFrameContext writes data as a frame.
+// It returns a *FrameTooLargeError if data exceeds the maximum frame size,
+// and ErrDelimiter if data contains the delimiter of a delimited frame.
+func (fw *FrameWriter) WriteFrameContext(ctx context.Context, data []byte) error {
+	if uint64(len(data)) > fw.max {
+		return &FrameTooLargeError{Size: uint64(len(data)), Max: fw.max}
+	}
+
+	pool := ctxio.BufferPoolFromContext(ctx)
+	var buf []byte
+	if fw.delimited {
+		if bytes.IndexByte(data, fw.delim) >= 0 {
+			return ErrDelimiter
+		}
+		buf = pool.Get(len(data) + 1)[:0]
+		buf = append(buf, data...)
+		buf = append(buf, fw.delim)
+	} else {
+		buf = pool.Get(len(data) + 10)[:0]
+		buf = fw.prefix.appendSize(buf, uint64(len(data)))
+		buf = append(buf, data...)
+	}
+	_, err := fw.w.WriteContext(ctx, buf)
+	pool.Put(buf)
+	return err
+}
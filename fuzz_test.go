@@ -0,0 +1,320 @@
+package ctxio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// The fuzz targets in this file run the same scripted readers and writers
+// through the ctxio helpers and their io counterparts,
+// and check that they produce the same output, counts and errors.
+
+// scriptedReader returns data in chunks of the sizes in sizes,
+// and then returns err.
+type scriptedReader struct {
+	data    []byte
+	sizes   []byte
+	i       int
+	err     error
+	dataErr bool // return err together with the last chunk
+}
+
+func newScriptedReader(data, sizes []byte, ctl uint8) *scriptedReader {
+	r := &scriptedReader{data: data, sizes: sizes}
+	switch ctl % 4 {
+	case 0, 1:
+		r.err = io.EOF
+	case 2:
+		r.err = errTest
+	case 3:
+		r.err = io.ErrUnexpectedEOF
+	}
+	r.dataErr = ctl&4 != 0
+	return r
+}
+
+func (r *scriptedReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	size := 1
+	if len(r.sizes) > 0 {
+		size = int(r.sizes[r.i%len(r.sizes)])%64 + 1
+		r.i++
+	}
+	if size > len(p) {
+		size = len(p)
+	}
+	n := copy(p[:size], r.data)
+	r.data = r.data[n:]
+	if len(r.data) == 0 && r.dataErr {
+		return n, r.err
+	}
+	return n, nil
+}
+
+func (r *scriptedReader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	return r.Read(p)
+}
+
+// scriptedWriter accepts limit bytes, and then misbehaves in the way selected by mode.
+// A negative limit means no limit.
+type scriptedWriter struct {
+	buf   bytes.Buffer
+	limit int
+	mode  uint8
+}
+
+func newScriptedWriter(limit int, ctl uint8) *scriptedWriter {
+	return &scriptedWriter{limit: limit, mode: (ctl >> 3) % 4}
+}
+
+func (w *scriptedWriter) Write(p []byte) (int, error) {
+	if w.limit < 0 || len(p) <= w.limit {
+		if w.limit >= 0 {
+			w.limit -= len(p)
+		}
+		return w.buf.Write(p)
+	}
+	n, _ := w.buf.Write(p[:w.limit])
+	w.limit = 0
+	switch w.mode {
+	case 0:
+		return n, errTest
+	case 1:
+		return n, nil // short write
+	case 2:
+		return len(p) + 1, nil // invalid count
+	default:
+		return -1, nil // invalid count
+	}
+}
+
+func (w *scriptedWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	return w.Write(p)
+}
+
+// ioReaderFrom and ctxReaderFrom give a scriptedWriter the ReaderFrom fast path.
+type ioReaderFrom struct{ *scriptedWriter }
+
+func (w ioReaderFrom) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{w.scriptedWriter}, r)
+}
+
+type ctxReaderFrom struct{ *scriptedWriter }
+
+func (w ctxReaderFrom) ReadFromContext(ctx context.Context, r Reader) (int64, error) {
+	return Copy(ctx, writerOnly{w.scriptedWriter}, r)
+}
+
+// ioWriterTo and ctxWriterTo give a scriptedReader the WriterTo fast path.
+type ioWriterTo struct{ *scriptedReader }
+
+func (r ioWriterTo) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, struct{ io.Reader }{r.scriptedReader})
+}
+
+type ctxWriterTo struct{ *scriptedReader }
+
+func (r ctxWriterTo) WriteToContext(ctx context.Context, w Writer) (int64, error) {
+	return Copy(ctx, w, readerOnly{r.scriptedReader})
+}
+
+// sameError reports whether got, returned by a ctxio helper,
+// corresponds to want, returned by the io counterpart.
+// The ctxio helpers wrap errors in *OpError, and the unexported errors of the io package
+// are compared by their messages.
+func sameError(got, want error) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+	if errors.Is(got, want) {
+		return true
+	}
+	for {
+		next := errors.Unwrap(got)
+		if next == nil {
+			break
+		}
+		got = next
+	}
+	return got.Error() == want.Error()
+}
+
+func FuzzCopy(f *testing.F) {
+	f.Add([]byte("hello, world"), []byte{3, 5}, uint8(0), -1, uint16(0))
+	f.Add([]byte("hello, world"), []byte{1}, uint8(4), 5, uint16(2))
+	f.Add([]byte("hello, world"), []byte{0}, uint8(2), 3, uint16(1))
+	f.Add([]byte("hello, world"), []byte{7}, uint8(8), 4, uint16(3))
+	f.Add([]byte("hello, world"), []byte{7}, uint8(16), 4, uint16(0))
+	f.Add([]byte("hello, world"), []byte{7}, uint8(24|64), 4, uint16(0))
+	f.Add([]byte("hello, world"), []byte{9, 2}, uint8(128|4|2), 100, uint16(5))
+	f.Add([]byte(""), []byte{}, uint8(2), -1, uint16(0))
+	f.Fuzz(func(t *testing.T, data, sizes []byte, ctl uint8, limit int, bufSize uint16) {
+		var buf []byte
+		if bufSize > 0 {
+			buf = make([]byte, bufSize%1024)
+			if len(buf) == 0 {
+				buf = nil
+			}
+		}
+		ioSrc, ctxSrc := newScriptedReader(data, sizes, ctl), newScriptedReader(data, sizes, ctl)
+		ioDst, ctxDst := newScriptedWriter(limit, ctl), newScriptedWriter(limit, ctl)
+
+		var ir io.Reader = ioSrc
+		var cr Reader = ctxSrc
+		if ctl&64 != 0 {
+			ir, cr = ioWriterTo{ioSrc}, ctxWriterTo{ctxSrc}
+		}
+		var iw io.Writer = ioDst
+		var cw Writer = ctxDst
+		if ctl&128 != 0 {
+			iw, cw = ioReaderFrom{ioDst}, ctxReaderFrom{ctxDst}
+		}
+
+		want, wantErr := io.CopyBuffer(iw, ir, buf)
+		got, gotErr := CopyBuffer(context.Background(), cw, cr, buf)
+		if got != want {
+			t.Errorf("CopyBuffer = %d, io.CopyBuffer = %d", got, want)
+		}
+		if !sameError(gotErr, wantErr) {
+			t.Errorf("CopyBuffer error = %v, io.CopyBuffer error = %v", gotErr, wantErr)
+		}
+		if !bytes.Equal(ctxDst.buf.Bytes(), ioDst.buf.Bytes()) {
+			t.Errorf("CopyBuffer wrote %q, io.CopyBuffer wrote %q", ctxDst.buf.Bytes(), ioDst.buf.Bytes())
+		}
+	})
+}
+
+func FuzzReadAtLeast(f *testing.F) {
+	f.Add([]byte("hello, world"), []byte{3, 5}, uint8(0), uint16(12), uint16(5))
+	f.Add([]byte("hello, world"), []byte{3, 5}, uint8(0), uint16(12), uint16(20))
+	f.Add([]byte("hello"), []byte{1}, uint8(4), uint16(10), uint16(10))
+	f.Add([]byte("hello"), []byte{1}, uint8(2), uint16(10), uint16(3))
+	f.Add([]byte(""), []byte{}, uint8(0), uint16(10), uint16(0))
+	f.Add([]byte("hello"), []byte{1}, uint8(0), uint16(3), uint16(10))
+	f.Fuzz(func(t *testing.T, data, sizes []byte, ctl uint8, bufLen, min uint16) {
+		bufLen %= 1024
+		min %= 1024
+		ioSrc, ctxSrc := newScriptedReader(data, sizes, ctl), newScriptedReader(data, sizes, ctl)
+		ioBuf, ctxBuf := make([]byte, bufLen), make([]byte, bufLen)
+
+		want, wantErr := io.ReadAtLeast(ioSrc, ioBuf, int(min))
+		got, gotErr := ReadAtLeast(context.Background(), ctxSrc, ctxBuf, int(min))
+		if got != want || gotErr != wantErr {
+			t.Errorf("ReadAtLeast = %d, %v, io.ReadAtLeast = %d, %v", got, gotErr, want, wantErr)
+		}
+		if !bytes.Equal(ctxBuf, ioBuf) {
+			t.Errorf("ReadAtLeast read %q, io.ReadAtLeast read %q", ctxBuf, ioBuf)
+		}
+
+		ioSrc, ctxSrc = newScriptedReader(data, sizes, ctl), newScriptedReader(data, sizes, ctl)
+		want, wantErr = io.ReadFull(ioSrc, ioBuf)
+		got, gotErr = ReadFull(context.Background(), ctxSrc, ctxBuf)
+		if got != want || gotErr != wantErr {
+			t.Errorf("ReadFull = %d, %v, io.ReadFull = %d, %v", got, gotErr, want, wantErr)
+		}
+		if !bytes.Equal(ctxBuf, ioBuf) {
+			t.Errorf("ReadFull read %q, io.ReadFull read %q", ctxBuf, ioBuf)
+		}
+	})
+}
+
+func FuzzReadAll(f *testing.F) {
+	f.Add([]byte("hello, world"), []byte{3, 5}, uint8(0))
+	f.Add([]byte("hello, world"), []byte{63}, uint8(2))
+	f.Add([]byte("hello, world"), []byte{1}, uint8(6))
+	f.Add(bytes.Repeat([]byte("0123456789"), 200), []byte{63, 1}, uint8(3))
+	f.Add([]byte(""), []byte{}, uint8(2))
+	f.Fuzz(func(t *testing.T, data, sizes []byte, ctl uint8) {
+		want, wantErr := io.ReadAll(newScriptedReader(data, sizes, ctl))
+		got, gotErr := ReadAll(context.Background(), newScriptedReader(data, sizes, ctl))
+		if !bytes.Equal(got, want) {
+			t.Errorf("ReadAll = %q, io.ReadAll = %q", got, want)
+		}
+		if !sameError(gotErr, wantErr) {
+			t.Errorf("ReadAll error = %v, io.ReadAll error = %v", gotErr, wantErr)
+		}
+	})
+}
+
+func FuzzReadAllLimit(f *testing.F) {
+	f.Add([]byte("hello, world"), []byte{3, 5}, uint8(0), uint16(5))
+	f.Add([]byte("hello, world"), []byte{63}, uint8(6), uint16(12))
+	f.Add([]byte("hello, world"), []byte{1}, uint8(2), uint16(0))
+	f.Add(bytes.Repeat([]byte("0123456789"), 200), []byte{63, 1}, uint8(3), uint16(1000))
+	f.Fuzz(func(t *testing.T, data, sizes []byte, ctl uint8, limit uint16) {
+		want, wantErr := io.ReadAll(newScriptedReader(data, sizes, ctl))
+		if len(want) > int(limit) {
+			want, wantErr = want[:limit], ErrTooLarge
+		}
+		got, gotErr := ReadAllLimit(context.Background(), newScriptedReader(data, sizes, ctl), int64(limit))
+		if !bytes.Equal(got, want) {
+			t.Errorf("ReadAllLimit = %q, want %q", got, want)
+		}
+		if !sameError(gotErr, wantErr) {
+			t.Errorf("ReadAllLimit error = %v, want %v", gotErr, wantErr)
+		}
+	})
+}
+
+func FuzzAppendAll(f *testing.F) {
+	f.Add([]byte("hello, world"), []byte{3, 5}, uint8(0), []byte("prefix"), uint8(0))
+	f.Add([]byte("hello, world"), []byte{1}, uint8(6), []byte{}, uint8(64))
+	f.Fuzz(func(t *testing.T, data, sizes []byte, ctl uint8, prefix []byte, spare uint8) {
+		want, wantErr := io.ReadAll(newScriptedReader(data, sizes, ctl))
+		want = append(append([]byte{}, prefix...), want...)
+		dst := append(make([]byte, 0, len(prefix)+int(spare)), prefix...)
+		got, gotErr := AppendAll(context.Background(), dst, newScriptedReader(data, sizes, ctl))
+		if !bytes.Equal(got, want) {
+			t.Errorf("AppendAll = %q, want %q", got, want)
+		}
+		if !sameError(gotErr, wantErr) {
+			t.Errorf("AppendAll error = %v, io.ReadAll error = %v", gotErr, wantErr)
+		}
+	})
+}
+
+func FuzzDiscard(f *testing.F) {
+	f.Add([]byte("hello, world"), []byte{3, 5}, uint8(0))
+	f.Add([]byte("hello, world"), []byte{1}, uint8(6))
+	f.Fuzz(func(t *testing.T, data, sizes []byte, ctl uint8) {
+		want, wantErr := io.Copy(io.Discard, newScriptedReader(data, sizes, ctl))
+		got, gotErr := Copy(context.Background(), Discard, newScriptedReader(data, sizes, ctl))
+		if got != want {
+			t.Errorf("Copy to Discard = %d, io.Copy to io.Discard = %d", got, want)
+		}
+		if !sameError(gotErr, wantErr) {
+			t.Errorf("Copy to Discard error = %v, io.Copy to io.Discard error = %v", gotErr, wantErr)
+		}
+	})
+}
+
+// FuzzCopyReadAhead checks that CopyReadAhead produces the same results as CopyBuffer.
+func FuzzCopyReadAhead(f *testing.F) {
+	f.Add([]byte("hello, world"), []byte{3, 5}, uint8(0), -1, uint16(4), uint8(1))
+	f.Add([]byte("hello, world"), []byte{1}, uint8(4), 5, uint16(2), uint8(0))
+	f.Add([]byte("hello, world"), []byte{0}, uint8(2), 3, uint16(1), uint8(3))
+	f.Add([]byte("hello, world"), []byte{7}, uint8(8), 4, uint16(3), uint8(2))
+	f.Add([]byte("hello, world"), []byte{7}, uint8(16), 4, uint16(0), uint8(1))
+	f.Add([]byte("hello, world"), []byte{7}, uint8(24), 4, uint16(0), uint8(1))
+	f.Fuzz(func(t *testing.T, data, sizes []byte, ctl uint8, limit int, bufSize uint16, depth uint8) {
+		size := int(bufSize%1024) + 1
+		wantDst, gotDst := newScriptedWriter(limit, ctl), newScriptedWriter(limit, ctl)
+		want, wantErr := CopyBuffer(context.Background(), writerOnly{wantDst}, readerOnly{newScriptedReader(data, sizes, ctl)}, make([]byte, size))
+		opts := &ReadAheadOptions{Depth: int(depth % 8), BufferSize: size}
+		got, gotErr := CopyReadAhead(context.Background(), gotDst, newScriptedReader(data, sizes, ctl), opts)
+		if got != want {
+			t.Errorf("CopyReadAhead = %d, CopyBuffer = %d", got, want)
+		}
+		if (gotErr == nil) != (wantErr == nil) || gotErr != nil && gotErr.Error() != wantErr.Error() {
+			t.Errorf("CopyReadAhead error = %v, CopyBuffer error = %v", gotErr, wantErr)
+		}
+		if !bytes.Equal(gotDst.buf.Bytes(), wantDst.buf.Bytes()) {
+			t.Errorf("CopyReadAhead wrote %q, CopyBuffer wrote %q", gotDst.buf.Bytes(), wantDst.buf.Bytes())
+		}
+	})
+}
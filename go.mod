@@ -1,3 +1,3 @@
 module github.com/shogo82148/ctxio
 
-go 1.19
+go 1.20
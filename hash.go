@@ -0,0 +1,180 @@
+package ctxio
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+)
+
+// HashReader is a Reader that writes the bytes read from the underlying Reader to hashes.
+type HashReader struct {
+	r Reader
+	h io.Writer
+}
+
+var _ WriterTo = (*HashReader)(nil)
+
+// NewHashReader returns a HashReader that reads from r and writes the bytes read to hashes.
+func NewHashReader(r Reader, hashes ...hash.Hash) *HashReader {
+	return &HashReader{r: r, h: multiHash(hashes)}
+}
+
+func (r *HashReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	n, err := r.r.ReadContext(ctx, data)
+	if n > 0 && n <= len(data) {
+		r.h.Write(data[:n])
+	}
+	return n, err
+}
+
+// WriteToContext implements WriterTo.
+// It uses the WriterTo of the underlying Reader if available.
+func (r *HashReader) WriteToContext(ctx context.Context, w Writer) (int64, error) {
+	if wt, ok := r.r.(WriterTo); ok {
+		return wt.WriteToContext(ctx, &hashWriter{w: w, h: r.h})
+	}
+	return copyBuffer(ctx, w, readerOnly{r}, nil)
+}
+
+// HashWriter is a Writer that writes the bytes written to the underlying Writer to hashes.
+//
+// HashWriter doesn't implement ReaderFrom even if the underlying Writer does,
+// because the bytes accepted by the ReaderFrom are unknown.
+type HashWriter struct {
+	w Writer
+	h io.Writer
+}
+
+// NewHashWriter returns a HashWriter that writes to w and writes the bytes written to hashes.
+// Only the bytes accepted by w are hashed.
+func NewHashWriter(w Writer, hashes ...hash.Hash) *HashWriter {
+	return &HashWriter{w: w, h: multiHash(hashes)}
+}
+
+func (w *HashWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	n, err := w.w.WriteContext(ctx, data)
+	if n > 0 && n <= len(data) {
+		w.h.Write(data[:n])
+	}
+	return n, err
+}
+
+func multiHash(hashes []hash.Hash) io.Writer {
+	if len(hashes) == 1 {
+		return hashes[0]
+	}
+	ws := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		ws[i] = h
+	}
+	return io.MultiWriter(ws...)
+}
+
+// hashWriter writes the bytes written to h.
+type hashWriter struct {
+	w Writer
+	h io.Writer
+}
+
+func (w *hashWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	n, err := w.w.WriteContext(ctx, data)
+	if n > 0 && n <= len(data) {
+		w.h.Write(data[:n])
+	}
+	return n, err
+}
+
+// ErrChecksumMismatch is returned by the reader returned by NewVerifyingReader
+// when the digest of the bytes read doesn't match the expected one.
+// The error returned is a *ChecksumError, and errors.Is reports it matches ErrChecksumMismatch.
+var ErrChecksumMismatch = errors.New("ctxio: checksum mismatch")
+
+// ChecksumError describes a checksum mismatch.
+type ChecksumError struct {
+	// Expected is the expected digest.
+	Expected []byte
+
+	// Actual is the digest of the bytes read.
+	Actual []byte
+}
+
+func (e *ChecksumError) Error() string {
+	return "ctxio: checksum mismatch: expected " + hex.EncodeToString(e.Expected) + ", got " + hex.EncodeToString(e.Actual)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// NewVerifyingReader returns a Reader that reads from r and writes the bytes read to h.
+// At EOF, it compares the digest of h with expected, and returns a *ChecksumError
+// instead of io.EOF if they don't match.
+// h must be reset before reading.
+//
+// The returned Reader implements WriterTo if r does, so Copy can use its fast path;
+// Copy reports the mismatch as its error.
+func NewVerifyingReader(r Reader, h hash.Hash, expected []byte) Reader {
+	vr := &verifyingReader{
+		h:        h,
+		expected: expected,
+	}
+	vr.r.r = r
+	vr.r.h = h
+	if _, ok := r.(WriterTo); ok {
+		return &verifyingWriterTo{vr}
+	}
+	return vr
+}
+
+type verifyingReader struct {
+	r        HashReader
+	h        hash.Hash
+	expected []byte
+	err      error // the result of the verification
+}
+
+func (r *verifyingReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.r.ReadContext(ctx, data)
+	if err == io.EOF {
+		err = r.verify(int64(n))
+	}
+	return n, err
+}
+
+// verify compares the digest with the expected one. n is the number of bytes of the operation.
+func (r *verifyingReader) verify(n int64) error {
+	if r.err == nil {
+		r.err = io.EOF
+		if actual := r.h.Sum(nil); !bytes.Equal(actual, r.expected) {
+			r.err = wrapError(OpRead, "", n, &ChecksumError{Expected: r.expected, Actual: actual})
+		}
+	}
+	return r.err
+}
+
+type verifyingWriterTo struct {
+	*verifyingReader
+}
+
+func (r *verifyingWriterTo) WriteToContext(ctx context.Context, w Writer) (int64, error) {
+	if r.err != nil {
+		if r.err == io.EOF {
+			return 0, nil
+		}
+		return 0, r.err
+	}
+	n, err := r.r.WriteToContext(ctx, w)
+	if err != nil {
+		return n, err
+	}
+	if err := r.verify(n); err != io.EOF {
+		return n, err
+	}
+	return n, nil
+}
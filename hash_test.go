@@ -0,0 +1,203 @@
+package ctxio
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHashReader(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	want256 := sha256.Sum256([]byte(content))
+	wantMD5 := md5.Sum([]byte(content))
+
+	for _, fast := range []bool{false, true} {
+		h256, hMD5 := sha256.New(), md5.New()
+		src := &writerToBuffer{}
+		src.WriteString(content)
+		var r Reader = src
+		if !fast {
+			r = readerOnly{src}
+		}
+		var buf Buffer
+		n, err := Copy(context.Background(), &buf, NewHashReader(r, h256, hMD5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src.called != fast {
+			t.Errorf("fast path %v: want WriterTo called %v, got %v", fast, fast, src.called)
+		}
+		if n != int64(len(content)) || buf.String() != content {
+			t.Errorf("fast path %v: content mismatch", fast)
+		}
+		if !bytes.Equal(h256.Sum(nil), want256[:]) {
+			t.Errorf("fast path %v: sha256 mismatch", fast)
+		}
+		if !bytes.Equal(hMD5.Sum(nil), wantMD5[:]) {
+			t.Errorf("fast path %v: md5 mismatch", fast)
+		}
+	}
+}
+
+func TestHashWriter(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	want := sha256.Sum256([]byte(content))
+
+	h := sha256.New()
+	dst := &readerFromBuffer{}
+	src := &Buffer{}
+	src.WriteString(content)
+	if _, err := Copy(context.Background(), NewHashWriter(dst, h), src); err != nil {
+		t.Fatal(err)
+	}
+	// the ReaderFrom is not used, because the bytes it accepts are unknown.
+	if dst.called {
+		t.Error("want ReaderFrom not called")
+	}
+	if dst.String() != content {
+		t.Error("content mismatch")
+	}
+	if !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Error("sha256 mismatch")
+	}
+}
+
+func TestHashWriter_CopyShortWrite(t *testing.T) {
+	h := sha256.New()
+	src := &Buffer{}
+	src.WriteString("hello")
+	if _, err := Copy(context.Background(), NewHashWriter(&errWriter{limit: 3, err: errTest}, h), src); !errors.Is(err, errTest) {
+		t.Errorf("want errTest, got %v", err)
+	}
+	want := sha256.Sum256([]byte("hel"))
+	if !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Error("only the bytes written should be hashed")
+	}
+}
+
+func TestHashReader_InvalidCount(t *testing.T) {
+	h := sha256.New()
+	r := NewHashReader(&invalidCountReader{n: 10}, h)
+	if n, _ := r.ReadContext(context.Background(), make([]byte, 5)); n != 10 {
+		t.Errorf("want 10, got %d", n)
+	}
+	want := sha256.Sum256(nil)
+	if !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Error("an invalid count must not be hashed")
+	}
+}
+
+// invalidCountReader returns n regardless of the length of the buffer.
+type invalidCountReader struct {
+	n int
+}
+
+func (r *invalidCountReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	return r.n, nil
+}
+
+func TestHashWriter_ShortWrite(t *testing.T) {
+	h := sha256.New()
+	w := NewHashWriter(&errWriter{limit: 3, err: errTest}, h)
+	if _, err := w.WriteContext(context.Background(), []byte("hello")); err != errTest {
+		t.Errorf("want errTest, got %v", err)
+	}
+	want := sha256.Sum256([]byte("hel"))
+	if !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Error("only the bytes written should be hashed")
+	}
+}
+
+func TestVerifyingReader(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	sum := sha256.Sum256([]byte(content))
+
+	for _, tt := range []struct {
+		name string
+		r    func() Reader
+	}{
+		{"read", func() Reader { return readerOnly{NewReader(strings.NewReader(content))} }},
+		{"WriterTo", func() Reader {
+			b := &writerToBuffer{}
+			b.WriteString(content)
+			return b
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewVerifyingReader(tt.r(), sha256.New(), sum[:])
+			if _, ok := r.(WriterTo); ok != (tt.name == "WriterTo") {
+				t.Errorf("want WriterTo %v, got %v", !ok, ok)
+			}
+			got, err := ReadAll(context.Background(), r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != content {
+				t.Error("content mismatch")
+			}
+
+			var buf Buffer
+			r = NewVerifyingReader(tt.r(), sha256.New(), sum[:])
+			if _, err := Copy(context.Background(), &buf, r); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != content {
+				t.Error("content mismatch")
+			}
+		})
+	}
+}
+
+func TestVerifyingReader_Mismatch(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	sum := sha256.Sum256([]byte("something else"))
+
+	for _, tt := range []struct {
+		name string
+		r    func() Reader
+	}{
+		{"read", func() Reader { return readerOnly{NewReader(strings.NewReader(content))} }},
+		{"WriterTo", func() Reader {
+			b := &writerToBuffer{}
+			b.WriteString(content)
+			return b
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewVerifyingReader(tt.r(), sha256.New(), sum[:])
+			_, err := ReadAll(context.Background(), r)
+			if !errors.Is(err, ErrChecksumMismatch) {
+				t.Fatalf("want ErrChecksumMismatch, got %v", err)
+			}
+			var ce *ChecksumError
+			if !errors.As(err, &ce) || !bytes.Equal(ce.Expected, sum[:]) {
+				t.Errorf("want ChecksumError, got %v", err)
+			}
+			// the error is sticky.
+			if _, err := r.ReadContext(context.Background(), make([]byte, 10)); !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("want ErrChecksumMismatch, got %v", err)
+			}
+
+			var buf Buffer
+			r = NewVerifyingReader(tt.r(), sha256.New(), sum[:])
+			if _, err := Copy(context.Background(), writerOnly{&buf}, r); !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("want ErrChecksumMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyingReader_Error(t *testing.T) {
+	r := NewVerifyingReader(&errReader{data: []byte("hello"), err: errTest}, sha256.New(), nil)
+	if _, err := ReadAll(context.Background(), r); !errors.Is(err, errTest) {
+		t.Errorf("want errTest, got %v", err)
+	}
+	if _, err := r.ReadContext(context.Background(), make([]byte, 10)); errors.Is(err, ErrChecksumMismatch) || err == io.EOF {
+		t.Errorf("the digest must not be verified before EOF, got %v", err)
+	}
+}
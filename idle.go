@@ -0,0 +1,137 @@
+package ctxio
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIdleTimeout is returned by the readers and writers returned by
+// IdleTimeoutReader and IdleTimeoutWriter when no bytes are transferred
+// for the idle period.
+var ErrIdleTimeout error = idleTimeoutError{}
+
+type idleTimeoutError struct{}
+
+func (idleTimeoutError) Error() string { return "ctxio: idle timeout" }
+func (idleTimeoutError) Timeout() bool { return true }
+
+// IdleTimeoutReader returns a Reader that reads from r, and fails with ErrIdleTimeout
+// if a read doesn't return any bytes for d.
+//
+// If r is returned by NewReader for a reader that supports read deadlines,
+// such as net.Conn and *os.File of a pipe, the read deadline is used
+// instead of a timer.
+func IdleTimeoutReader(r Reader, d time.Duration) Reader {
+	ir := &idleTimeoutReader{
+		r:    r,
+		idle: idleTimer{d: d},
+	}
+	ir.wr, _ = r.(*watchReader)
+	return ir
+}
+
+type idleTimeoutReader struct {
+	r    Reader
+	wr   *watchReader
+	idle idleTimer
+}
+
+func (r *idleTimeoutReader) ReadContext(ctx context.Context, data []byte) (n int, err error) {
+	clock := ClockFromContext(ctx)
+	if r.wr != nil && isSystemClock(clock) {
+		return r.wr.readContext(ctx, data, r.idle.deadline())
+	}
+
+	ctx, stop, expired := r.idle.context(ctx, clock)
+	defer stop()
+	n, err = r.r.ReadContext(ctx, data)
+	if err != nil && expired() {
+		err = wrapError(OpRead, "", int64(n), ErrIdleTimeout)
+	}
+	return
+}
+
+// IdleTimeoutWriter returns a Writer that writes to w, and fails with ErrIdleTimeout
+// if no bytes are written for d.
+// Large writes are split into chunks, and the idle period restarts after each chunk.
+//
+// If w is returned by NewWriter for a writer that supports write deadlines,
+// such as net.Conn and *os.File of a pipe, the write deadline is used
+// instead of a timer.
+func IdleTimeoutWriter(w Writer, d time.Duration) Writer {
+	iw := &idleTimeoutWriter{
+		w:    w,
+		idle: idleTimer{d: d},
+	}
+	iw.ww, _ = w.(*watchWriter)
+	return iw
+}
+
+type idleTimeoutWriter struct {
+	w    Writer
+	ww   *watchWriter
+	idle idleTimer
+}
+
+func (w *idleTimeoutWriter) WriteContext(ctx context.Context, data []byte) (n int, err error) {
+	if len(data) == 0 {
+		return w.writeContext(ctx, data)
+	}
+	for n < len(data) {
+		end := n + writeBufferSize
+		if end > len(data) {
+			end = len(data)
+		}
+		var m int
+		m, err = w.writeContext(ctx, data[n:end])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (w *idleTimeoutWriter) writeContext(ctx context.Context, data []byte) (n int, err error) {
+	clock := ClockFromContext(ctx)
+	if w.ww != nil && isSystemClock(clock) {
+		return w.ww.writeContext(ctx, data, w.idle.deadline())
+	}
+
+	ctx, stop, expired := w.idle.context(ctx, clock)
+	defer stop()
+	n, err = w.w.WriteContext(ctx, data)
+	if err != nil && expired() {
+		err = wrapError(OpWrite, "", int64(n), ErrIdleTimeout)
+	}
+	return
+}
+
+type idleTimer struct {
+	d time.Duration
+}
+
+// deadline returns the deadline of a call starting now.
+func (t idleTimer) deadline() time.Time {
+	return time.Now().Add(t.d)
+}
+
+// context returns a context that is canceled after the idle period measured by clock.
+// expired reports whether the context is canceled by the idle timer.
+func (t idleTimer) context(parent context.Context, clock Clock) (ctx context.Context, stop func(), expired func() bool) {
+	ctx, cancel := context.WithCancel(parent)
+	var timedOut atomic.Bool
+	tm := clock.AfterFunc(t.d, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+	stop = func() {
+		tm.Stop()
+		cancel()
+	}
+	expired = func() bool {
+		return timedOut.Load() && parent.Err() == nil
+	}
+	return ctx, stop, expired
+}
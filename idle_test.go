@@ -0,0 +1,161 @@
+package ctxio_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+func TestIdleTimeoutReader(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := ctxio.WithClock(context.Background(), clock)
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+	r := ctxio.IdleTimeoutReader(pr, time.Second)
+
+	go func() {
+		pw.WriteContext(ctx, []byte("hello"))
+	}()
+	buf := make([]byte, 64)
+	n, err := r.ReadContext(ctx, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Errorf("want %q, got %q", "hello", buf[:n])
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.ReadContext(ctx, buf)
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	err = <-done
+	if !errors.Is(err, ctxio.ErrIdleTimeout) {
+		t.Fatalf("want ErrIdleTimeout, got %v", err)
+	}
+	var opErr *ctxio.OpError
+	if !errors.As(err, &opErr) || !opErr.Timeout() {
+		t.Errorf("want timeout *OpError, got %v", err)
+	}
+}
+
+func TestIdleTimeoutReader_Canceled(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+	r := ctxio.IdleTimeoutReader(pr, time.Second)
+
+	ctx, cancel := context.WithCancel(ctxio.WithClock(context.Background(), clock))
+	cancel()
+	_, err := r.ReadContext(ctx, make([]byte, 64))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	if clock.Timers() != 0 {
+		t.Error("the idle timer is not stopped")
+	}
+}
+
+func TestIdleTimeoutWriter(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := ctxio.WithClock(context.Background(), clock)
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+	w := ctxio.IdleTimeoutWriter(pw, time.Second)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.WriteContext(ctx, []byte("hello"))
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if err := <-done; !errors.Is(err, ctxio.ErrIdleTimeout) {
+		t.Fatalf("want ErrIdleTimeout, got %v", err)
+	}
+}
+
+func TestIdleTimeoutReader_Watch(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	rr := ctxio.NewReader(r)
+	defer rr.Close()
+	ir := ctxio.IdleTimeoutReader(rr, 50*time.Millisecond)
+
+	buf := make([]byte, 64)
+	_, err = ir.ReadContext(context.Background(), buf)
+	if !errors.Is(err, ctxio.ErrIdleTimeout) {
+		t.Fatalf("want ErrIdleTimeout, got %v", err)
+	}
+
+	// the expired deadline is cleared when reading without the idle timeout.
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := rr.ReadContext(context.Background(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Errorf("want %q, got %q", "hello", buf[:n])
+	}
+
+	// the deadline is extended by the next read.
+	if _, err := w.Write([]byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	n, err = ir.ReadContext(context.Background(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "world" {
+		t.Errorf("want %q, got %q", "world", buf[:n])
+	}
+}
+
+func TestWatchReader_ReadAfterCancel(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	rr := ctxio.NewReader(r)
+	defer rr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	buf := make([]byte, 64)
+	if _, err := rr.ReadContext(ctx, buf); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+
+	// the deadline set by the cancellation must not affect the next read.
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := rr.ReadContext(context.Background(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Errorf("want %q, got %q", "hello", buf[:n])
+	}
+}
@@ -0,0 +1,39 @@
+// Package bridge adapts ctxio.Reader and ctxio.Writer to io.Reader and io.Writer,
+// so that they can be used by the packages that only know the io interfaces.
+package bridge
+
+import (
+	"context"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// Reader is an io.Reader that reads from R with Ctx.
+// If Ctx is nil, context.Background() is used.
+type Reader struct {
+	Ctx context.Context
+	R   ctxio.Reader
+}
+
+func (r *Reader) Read(data []byte) (int, error) {
+	ctx := r.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return r.R.ReadContext(ctx, data)
+}
+
+// Writer is an io.Writer that writes to W with Ctx.
+// If Ctx is nil, context.Background() is used.
+type Writer struct {
+	Ctx context.Context
+	W   ctxio.Writer
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	ctx := w.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return w.W.WriteContext(ctx, data)
+}
@@ -0,0 +1,103 @@
+package ctxio
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReadWriter is the interface that groups the ReadContext and WriteContext methods.
+type ReadWriter interface {
+	Reader
+	Writer
+}
+
+// closeWriter is implemented by connections that support half-close,
+// such as *net.TCPConn.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// JoinOptions configures Join.
+type JoinOptions struct {
+	// Linger is how long Join waits for the other direction
+	// after one direction finishes successfully.
+	// When it expires, the other direction is canceled.
+	// If Linger is zero, Join waits until the other direction finishes.
+	Linger time.Duration
+}
+
+// JoinResult is the result of one direction of Join.
+type JoinResult struct {
+	// N is the number of bytes copied.
+	N int64
+
+	// Err is the error of the copy, or the error of CloseWrite.
+	Err error
+}
+
+// Join copies from a to b and from b to a concurrently, until both directions finish.
+//
+// When a direction reaches EOF, Join calls CloseWrite of the destination
+// if it has the method, so the peer sees EOF too.
+// When a direction fails, or ctx is done, both directions are canceled.
+// Join doesn't close a or b.
+//
+// If opts is nil, the zero JoinOptions is used.
+// The linger timer uses the clock carried by ctx; see WithClock.
+func Join(ctx context.Context, a, b ReadWriter, opts *JoinOptions) (aToB, bToA JoinResult) {
+	var linger time.Duration
+	if opts != nil {
+		linger = opts.Linger
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var timer Timer
+	finished := func(err error) {
+		if err != nil {
+			cancel()
+			return
+		}
+		if linger <= 0 {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if timer == nil {
+			timer = ClockFromContext(ctx).AfterFunc(linger, cancel)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		aToB = joinCopy(ctx, b, a)
+		finished(aToB.Err)
+	}()
+	go func() {
+		defer wg.Done()
+		bToA = joinCopy(ctx, a, b)
+		finished(bToA.Err)
+	}()
+	wg.Wait()
+
+	mu.Lock()
+	if timer != nil {
+		timer.Stop()
+	}
+	mu.Unlock()
+	return
+}
+
+func joinCopy(ctx context.Context, dst Writer, src Reader) JoinResult {
+	n, err := Copy(ctx, dst, src)
+	if err == nil {
+		if cw, ok := dst.(closeWriter); ok {
+			err = wrapError(OpCopy, SideDst, n, cw.CloseWrite())
+		}
+	}
+	return JoinResult{N: n, Err: err}
+}
@@ -0,0 +1,121 @@
+package ctxio_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+// joinConn is a ReadWriter that records CloseWrite.
+type joinConn struct {
+	ctxio.Reader
+	ctxio.Writer
+	closedWrite bool
+}
+
+func (c *joinConn) CloseWrite() error {
+	c.closedWrite = true
+	return nil
+}
+
+func newJoinConn(s string) (*joinConn, *bytes.Buffer) {
+	dst := &bytes.Buffer{}
+	return &joinConn{Reader: ctxio.NewReader(strings.NewReader(s)), Writer: ctxio.NewWriter(dst)}, dst
+}
+
+func TestJoin(t *testing.T) {
+	a, aOut := newJoinConn("request")
+	b, bOut := newJoinConn("response")
+	aToB, bToA := ctxio.Join(context.Background(), a, b, nil)
+	if aToB.Err != nil || bToA.Err != nil {
+		t.Fatal(aToB.Err, bToA.Err)
+	}
+	if aToB.N != 7 || bToA.N != 8 {
+		t.Errorf("want 7 and 8 bytes, got %d and %d", aToB.N, bToA.N)
+	}
+	if bOut.String() != "request" || aOut.String() != "response" {
+		t.Errorf("unexpected output: %q, %q", bOut.String(), aOut.String())
+	}
+	if !a.closedWrite || !b.closedWrite {
+		t.Error("CloseWrite is not called")
+	}
+}
+
+func TestJoin_Error(t *testing.T) {
+	a := &joinConn{Reader: ctxio.NewReader(io.MultiReader(strings.NewReader("hello"), iotest.ErrReader(errTest))), Writer: ctxio.NewWriter(&bytes.Buffer{})}
+	pr, pw := ctxio.Pipe()
+	defer pw.Close()
+	b := &joinConn{Reader: pr, Writer: ctxio.NewWriter(&bytes.Buffer{})}
+
+	aToB, bToA := ctxio.Join(context.Background(), a, b, nil)
+	if !errors.Is(aToB.Err, errTest) {
+		t.Errorf("want errTest, got %v", aToB.Err)
+	}
+	if aToB.N != 5 {
+		t.Errorf("want 5, got %d", aToB.N)
+	}
+	if !errors.Is(bToA.Err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", bToA.Err)
+	}
+	if a.closedWrite || b.closedWrite {
+		t.Error("CloseWrite must not be called on failure")
+	}
+}
+
+func TestJoin_Linger(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := ctxio.WithClock(context.Background(), clock)
+	a, _ := newJoinConn("hello")
+	pr, pw := ctxio.Pipe()
+	defer pw.Close()
+	b := &joinConn{Reader: pr, Writer: ctxio.NewWriter(&bytes.Buffer{})}
+
+	type result struct{ aToB, bToA ctxio.JoinResult }
+	done := make(chan result, 1)
+	go func() {
+		aToB, bToA := ctxio.Join(ctx, a, b, &ctxio.JoinOptions{Linger: time.Second})
+		done <- result{aToB, bToA}
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("Join returned before the linger expires")
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	res := <-done
+	if res.aToB.Err != nil || res.aToB.N != 5 {
+		t.Errorf("want 5, nil, got %d, %v", res.aToB.N, res.aToB.Err)
+	}
+	if !b.closedWrite {
+		t.Error("CloseWrite is not called")
+	}
+	if !errors.Is(res.bToA.Err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", res.bToA.Err)
+	}
+}
+
+func TestJoin_Canceled(t *testing.T) {
+	ar, aw := ctxio.Pipe()
+	defer aw.Close()
+	br, bw := ctxio.Pipe()
+	defer bw.Close()
+	a := &joinConn{Reader: ar, Writer: ctxio.NewWriter(&bytes.Buffer{})}
+	b := &joinConn{Reader: br, Writer: ctxio.NewWriter(&bytes.Buffer{})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	aToB, bToA := ctxio.Join(ctx, a, b, nil)
+	if !errors.Is(aToB.Err, context.Canceled) || !errors.Is(bToA.Err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v and %v", aToB.Err, bToA.Err)
+	}
+}
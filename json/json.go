@@ -0,0 +1,195 @@
+// Package json provides context-aware JSON encoders and decoders over ctxio streams.
+//
+// Encoder and Decoder wrap the ones of encoding/json, and pass the context of each call
+// down to the underlying ctxio.Writer or ctxio.Reader, so that canceling the context
+// interrupts a blocked read or write, not only the gap between values.
+//
+// The decoder keeps the first read error it encounters, except for context errors.
+// After a call of DecodeContext fails because of its context, the next call
+// decodes the same value again from the data already read, so no input is lost.
+package json
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/internal/bridge"
+)
+
+// A Decoder reads and decodes JSON values from an input stream.
+type Decoder struct {
+	br  reader
+	dec *json.Decoder
+
+	// pending is the data buffered by the decoders before the last rebuild,
+	// which is read before br.
+	pending bytes.Reader
+
+	// the options and the offset, carried over when dec is rebuilt.
+	useNumber       bool
+	disallowUnknown bool
+	offset          int64
+}
+
+// NewDecoder returns a new decoder that reads from r.
+//
+// The decoder introduces its own buffering and may
+// read data from r beyond the JSON values requested.
+func NewDecoder(r ctxio.Reader) *Decoder {
+	d := &Decoder{}
+	d.br.R = r
+	d.dec = json.NewDecoder(&d.br)
+	return d
+}
+
+// UseNumber causes the Decoder to unmarshal a number into an any as a
+// json.Number instead of as a float64.
+func (d *Decoder) UseNumber() {
+	d.useNumber = true
+	d.dec.UseNumber()
+}
+
+// DisallowUnknownFields causes the Decoder to return an error when the destination
+// is a struct and the input contains object keys which do not match any
+// non-ignored, exported fields in the destination.
+func (d *Decoder) DisallowUnknownFields() {
+	d.disallowUnknown = true
+	d.dec.DisallowUnknownFields()
+}
+
+// DecodeContext reads the next JSON-encoded value from its
+// input and stores it in the value pointed to by v.
+// If ctx is done before the value is read, DecodeContext returns the error of ctx,
+// and the next call starts over from the beginning of the same value.
+//
+// See the documentation for json.Unmarshal for details about
+// the conversion of JSON into a Go value.
+func (d *Decoder) DecodeContext(ctx context.Context, v any) error {
+	d.br.Ctx = ctx
+	defer func() { d.br.Ctx = nil }()
+	err := d.dec.Decode(v)
+	d.recover(ctx, err)
+	return err
+}
+
+// TokenContext returns the next JSON token in the input stream.
+// At the end of the input stream, TokenContext returns nil, io.EOF.
+// If ctx is done, the next call reads the same token again,
+// but the nesting of the arrays and objects read by the previous calls is forgotten,
+// so resume only at the top level.
+//
+// See (*json.Decoder).Token for details.
+func (d *Decoder) TokenContext(ctx context.Context) (json.Token, error) {
+	d.br.Ctx = ctx
+	defer func() { d.br.Ctx = nil }()
+	tok, err := d.dec.Token()
+	d.recover(ctx, err)
+	return tok, err
+}
+
+// MoreContext reports whether there is another element in the
+// current array or object being parsed.
+// If reading the input fails, MoreContext returns false and
+// the error is returned by the next call of DecodeContext or TokenContext.
+// If ctx is done before the next token is buffered, MoreContext returns false,
+// and the following calls read the input again.
+func (d *Decoder) MoreContext(ctx context.Context) bool {
+	d.br.Ctx = ctx
+	d.br.err = nil
+	defer func() { d.br.Ctx = nil }()
+	more := d.dec.More()
+	if !more {
+		// More may have seen the end of the array or the object
+		// from the buffered data, even if ctx is done.
+		d.recover(ctx, d.br.err)
+	}
+	return more
+}
+
+// recover rebuilds the decoder if err is caused by ctx,
+// because encoding/json keeps the first read error forever.
+// The data buffered by the old decoder is read again by the new one.
+func (d *Decoder) recover(ctx context.Context, err error) {
+	if err == nil || ctx.Err() == nil || !errors.Is(err, ctx.Err()) {
+		return
+	}
+	d.offset += d.dec.InputOffset()
+	buf, _ := io.ReadAll(d.Buffered())
+	d.pending.Reset(buf)
+	d.dec = json.NewDecoder(io.MultiReader(&d.pending, &d.br))
+	if d.useNumber {
+		d.dec.UseNumber()
+	}
+	if d.disallowUnknown {
+		d.dec.DisallowUnknownFields()
+	}
+}
+
+// Buffered returns a reader of the data remaining in the Decoder's
+// buffer. The reader is valid until the next call to DecodeContext.
+func (d *Decoder) Buffered() io.Reader {
+	if d.pending.Len() == 0 {
+		return d.dec.Buffered()
+	}
+	// read the pending data without consuming it.
+	n := int64(d.pending.Len())
+	return io.MultiReader(d.dec.Buffered(), io.NewSectionReader(&d.pending, d.pending.Size()-n, n))
+}
+
+// InputOffset returns the input stream byte offset of the current decoder position.
+func (d *Decoder) InputOffset() int64 {
+	return d.offset + d.dec.InputOffset()
+}
+
+// reader is a bridge.Reader that remembers the last read error.
+type reader struct {
+	bridge.Reader
+	err error
+}
+
+func (r *reader) Read(data []byte) (int, error) {
+	n, err := r.Reader.Read(data)
+	r.err = err
+	return n, err
+}
+
+// An Encoder writes JSON values to an output stream.
+type Encoder struct {
+	bw  bridge.Writer
+	enc *json.Encoder
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w ctxio.Writer) *Encoder {
+	e := &Encoder{}
+	e.bw.W = w
+	e.enc = json.NewEncoder(&e.bw)
+	return e
+}
+
+// EncodeContext writes the JSON encoding of v to the stream,
+// followed by a newline character.
+//
+// See the documentation for json.Marshal for details about the
+// conversion of Go values to JSON.
+func (e *Encoder) EncodeContext(ctx context.Context, v any) error {
+	e.bw.Ctx = ctx
+	defer func() { e.bw.Ctx = nil }()
+	return e.enc.Encode(v)
+}
+
+// SetIndent instructs the encoder to format each subsequent encoded
+// value as if indented by json.Indent.
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.enc.SetIndent(prefix, indent)
+}
+
+// SetEscapeHTML specifies whether problematic HTML characters
+// should be escaped inside JSON quoted strings.
+func (e *Encoder) SetEscapeHTML(on bool) {
+	e.enc.SetEscapeHTML(on)
+}
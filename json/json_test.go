@@ -0,0 +1,201 @@
+package json
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+)
+
+type message struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	ctx := context.Background()
+
+	go func() {
+		enc := NewEncoder(pw)
+		for i := 0; i < 3; i++ {
+			if err := enc.EncodeContext(ctx, message{ID: i, Text: "hello"}); err != nil {
+				t.Error(err)
+			}
+		}
+		pw.Close()
+	}()
+
+	dec := NewDecoder(pr)
+	for i := 0; ; i++ {
+		var msg message
+		err := dec.DecodeContext(ctx, &msg)
+		if err == io.EOF {
+			if i != 3 {
+				t.Errorf("want 3 messages, got %d", i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.ID != i || msg.Text != "hello" {
+			t.Errorf("unexpected message: %v", msg)
+		}
+	}
+}
+
+func TestDecoder_Cancel(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+
+	go func() {
+		// send a partial value.
+		pw.WriteContext(context.Background(), []byte(`{"id": 1, `))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	dec := NewDecoder(pr)
+	var msg message
+	if err := dec.DecodeContext(ctx, &msg); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestDecoder_Resume(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// cancel after the decoder has read a partial value.
+		pw.WriteContext(context.Background(), []byte(`{"id": 1, `))
+		cancel()
+	}()
+
+	dec := NewDecoder(pr)
+	dec.DisallowUnknownFields()
+	var msg message
+	if err := dec.DecodeContext(ctx, &msg); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	if b, _ := io.ReadAll(dec.Buffered()); string(b) != `{"id": 1, ` {
+		t.Errorf("want the partial value buffered, got %q", b)
+	}
+
+	go func() {
+		pw.WriteContext(context.Background(), []byte(`"text": "hello"} {"id": 2, "text": "world"}`))
+		pw.Close()
+	}()
+	for _, want := range []message{{1, "hello"}, {2, "world"}} {
+		var msg message
+		if err := dec.DecodeContext(context.Background(), &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg != want {
+			t.Errorf("want %v, got %v", want, msg)
+		}
+	}
+	if off := dec.InputOffset(); off != 53 {
+		t.Errorf("want offset 53, got %d", off)
+	}
+	if err := dec.DecodeContext(context.Background(), &msg); err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+}
+
+func TestEncoder_Cancel(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	enc := NewEncoder(pw)
+	if err := enc.EncodeContext(ctx, message{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestDecoder_Token(t *testing.T) {
+	ctx := context.Background()
+	in := `[{"id": 1, "text": "a"}, {"id": 2, "text": "b"}]`
+	dec := NewDecoder(ctxio.NewReader(strings.NewReader(in)))
+
+	tok, err := dec.TokenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != json.Delim('[') {
+		t.Fatalf("want [, got %v", tok)
+	}
+	var got []message
+	for dec.MoreContext(ctx) {
+		var msg message
+		if err := dec.DecodeContext(ctx, &msg); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg)
+	}
+	tok, err = dec.TokenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != json.Delim(']') {
+		t.Fatalf("want ], got %v", tok)
+	}
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 2 {
+		t.Errorf("unexpected messages: %v", got)
+	}
+}
+
+func TestDecoder_MoreDone(t *testing.T) {
+	ctx := context.Background()
+	dec := NewDecoder(ctxio.NewReader(strings.NewReader(`[1]`)))
+	for _, want := range []json.Token{json.Delim('['), float64(1)} {
+		tok, err := dec.TokenContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok != want {
+			t.Fatalf("want %v, got %v", want, tok)
+		}
+	}
+
+	// MoreContext sees the buffered ] even if ctx is done,
+	// and the nesting of the array is kept.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if dec.MoreContext(canceled) {
+		t.Error("want no more elements")
+	}
+	tok, err := dec.TokenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != json.Delim(']') {
+		t.Errorf("want ], got %v", tok)
+	}
+}
+
+func TestEncoder_SetIndent(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(ctxio.NewWriter(&buf))
+	enc.SetIndent("", "  ")
+	if err := enc.EncodeContext(context.Background(), map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"a\": 1\n}\n"
+	if buf.String() != want {
+		t.Errorf("want %q, got %q", want, buf.String())
+	}
+}
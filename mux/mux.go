@@ -0,0 +1,176 @@
+// Package mux multiplexes independent bidirectional streams
+// over a single pair of ctxio.Reader and ctxio.Writer, such as a TCP connection.
+//
+// Each stream has its own flow control window, so a stream that is not read
+// doesn't block the other streams, and its own cancellation through the
+// context passed to each operation.
+//
+// The frames are written by a dedicated goroutine of the Session,
+// so a canceled operation never leaves a frame cut in the middle of the connection.
+package mux
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// frame types.
+const (
+	frameData uint8 = iota
+	frameWindowUpdate
+	frameReset
+	frameGoAway
+)
+
+// frame flags.
+const (
+	// flagSYN opens a new stream. It is sent with a window update frame.
+	flagSYN uint8 = 1 << iota
+
+	// flagFIN half-closes the stream. It is sent with a data frame.
+	flagFIN
+)
+
+// headerSize is the size of the frame header:
+// type (1 byte), flags (1 byte), stream ID (4 bytes) and length of the payload (4 bytes).
+const headerSize = 10
+
+// initialWindow is the flow control window of a new stream.
+// It is a part of the protocol, so it can't be changed by the Config.
+const initialWindow = 64 << 10
+
+// maxPayload is the maximum payload size of the frames the session accepts.
+const maxPayload = 16 << 20
+
+type header struct {
+	typ    uint8
+	flags  uint8
+	stream uint32
+	length uint32
+}
+
+func (h header) append(buf []byte) []byte {
+	buf = append(buf, h.typ, h.flags)
+	buf = binary.BigEndian.AppendUint32(buf, h.stream)
+	buf = binary.BigEndian.AppendUint32(buf, h.length)
+	return buf
+}
+
+func parseHeader(buf []byte) header {
+	return header{
+		typ:    buf[0],
+		flags:  buf[1],
+		stream: binary.BigEndian.Uint32(buf[2:]),
+		length: binary.BigEndian.Uint32(buf[6:]),
+	}
+}
+
+// Config is the configuration of a Session.
+// The zero value is the default configuration.
+type Config struct {
+	// WindowSize is the receive window of each stream in bytes;
+	// it is how much data the peer may send before the stream is read.
+	// If it is less than 64KB, 64KB is used.
+	WindowSize uint32
+
+	// MaxFrameSize is the maximum payload size of the data frames sent by the session.
+	// If it is zero, 16KB is used.
+	MaxFrameSize uint32
+
+	// AcceptBacklog is the maximum number of streams opened by the peer
+	// and not accepted yet. Streams over the backlog are refused.
+	// If it is zero, 256 is used.
+	AcceptBacklog int
+}
+
+func (c *Config) windowSize() uint32 {
+	if c == nil || c.WindowSize < initialWindow {
+		return initialWindow
+	}
+	return c.WindowSize
+}
+
+func (c *Config) maxFrameSize() uint32 {
+	if c == nil || c.MaxFrameSize == 0 {
+		return 16 << 10
+	}
+	if c.MaxFrameSize > maxPayload {
+		return maxPayload
+	}
+	return c.MaxFrameSize
+}
+
+func (c *Config) acceptBacklog() int {
+	if c == nil || c.AcceptBacklog <= 0 {
+		return 256
+	}
+	return c.AcceptBacklog
+}
+
+// ErrSessionClosed is returned by the operations of a closed Session
+// and its streams.
+var ErrSessionClosed = errors.New("mux: session closed")
+
+// ErrProtocol is returned when the peer violates the protocol.
+// The session is closed.
+var ErrProtocol = errors.New("mux: protocol error")
+
+// ErrStreamClosed is returned by reads from a stream after Close.
+var ErrStreamClosed = errors.New("mux: use of closed stream")
+
+// ErrWriteClosed is returned by writes to a stream after CloseWrite or Close.
+var ErrWriteClosed = errors.New("mux: write to a closed stream")
+
+// ErrorCode describes why a stream is reset.
+// The codes other than the predefined ones are free for applications.
+type ErrorCode uint32
+
+const (
+	// CodeCancel means the stream is not needed anymore.
+	// Close sends it if the peer hasn't finished sending.
+	CodeCancel ErrorCode = 0
+
+	// CodeRefused means the stream is refused because of the accept backlog.
+	CodeRefused ErrorCode = 1
+
+	// CodeFlowControl means the peer sent more data than the window allows.
+	CodeFlowControl ErrorCode = 2
+)
+
+// StreamError is returned by the operations of a reset stream.
+type StreamError struct {
+	// Code is the error code passed to Reset.
+	Code ErrorCode
+
+	// Remote reports whether the peer reset the stream.
+	Remote bool
+}
+
+func (e *StreamError) Error() string {
+	if e.Remote {
+		return "mux: stream reset by peer with code " + strconv.FormatUint(uint64(e.Code), 10)
+	}
+	return "mux: stream reset with code " + strconv.FormatUint(uint64(e.Code), 10)
+}
+
+// notifier wakes up all goroutines waiting for an event.
+// It must be used with a mutex held.
+type notifier struct {
+	ch chan struct{}
+}
+
+// wait returns a channel closed by the next broadcast.
+func (n *notifier) wait() <-chan struct{} {
+	if n.ch == nil {
+		n.ch = make(chan struct{})
+	}
+	return n.ch
+}
+
+func (n *notifier) broadcast() {
+	if n.ch != nil {
+		close(n.ch)
+		n.ch = nil
+	}
+}
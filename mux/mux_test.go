@@ -0,0 +1,440 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+// newPair returns a client and a server connected by in-memory pipes.
+func newPair(t *testing.T, config *Config) (client, server *Session) {
+	t.Helper()
+	c2sR, c2sW := ctxio.Pipe()
+	s2cR, s2cW := ctxio.Pipe()
+	client = Client(s2cR, c2sW, config)
+	server = Server(c2sR, s2cW, config)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+		c2sR.Close()
+		c2sW.Close()
+		s2cR.Close()
+		s2cW.Close()
+	})
+	return client, server
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPair(t, nil)
+
+	cs, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.WriteContext(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.WriteContext(ctx, []byte("hello")); err != ErrWriteClosed {
+		t.Errorf("want ErrWriteClosed, got %v", err)
+	}
+
+	ss, err := server.AcceptContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.ID() != ss.ID() || cs.ID()%2 != 1 {
+		t.Errorf("unexpected stream IDs: client %d, server %d", cs.ID(), ss.ID())
+	}
+	data, err := ctxio.ReadAll(ctx, ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("want %q, got %q", "hello", data)
+	}
+
+	// the other direction is still open.
+	if _, err := ss.WriteContext(ctx, []byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ctxio.ReadAll(ctx, cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "world" {
+		t.Errorf("want %q, got %q", "world", data)
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// both ends forget the stream.
+	waitFor(func() bool { return client.NumStreams() == 0 && server.NumStreams() == 0 })
+}
+
+func TestStream_Conformance(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPair(t, nil)
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+
+	cs, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		cs.WriteContext(ctx, content)
+		cs.CloseWrite()
+	}()
+	ss, err := server.AcceptContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctxiotest.TestReader(ss, content); err != nil {
+		t.Error(err)
+	}
+	if err := ctxiotest.TestReaderCancel(cs); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStream_FlowControl(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPair(t, nil)
+
+	cs, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := server.AcceptContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the write blocks when the window of the peer is full.
+	data := bytes.Repeat([]byte("x"), 3*initialWindow)
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	n, err := cs.WriteContext(tctx, data)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+	if n != initialWindow {
+		t.Errorf("want %d, got %d", initialWindow, n)
+	}
+
+	// the stream that is not read doesn't block the others.
+	cs2, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs2.WriteContext(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	ss2, err := server.AcceptContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := ctxio.ReadFull(ctx, ss2, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// reading opens the window.
+	go func() {
+		cs.WriteContext(ctx, data[n:])
+		cs.CloseWrite()
+	}()
+	got, err := ctxio.ReadAll(ctx, ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(data) {
+		t.Errorf("want %d bytes, got %d", len(data), len(got))
+	}
+}
+
+func TestStream_WindowSize(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPair(t, &Config{WindowSize: 4 * initialWindow, MaxFrameSize: 1024})
+
+	cs, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := server.AcceptContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("x"), 4*initialWindow)
+	waitFor(func() bool {
+		ss.mu.Lock()
+		defer ss.mu.Unlock()
+		return ss.sendWindow == 4*initialWindow
+	})
+
+	// the whole data fits in the window.
+	if _, err := cs.WriteContext(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ss.WriteContext(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStream_Reset(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPair(t, nil)
+
+	cs, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.WriteContext(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	ss, err := server.AcceptContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func() bool {
+		ss.mu.Lock()
+		defer ss.mu.Unlock()
+		return len(ss.buf) == 5
+	})
+	if err := cs.Reset(42); err != nil {
+		t.Fatal(err)
+	}
+
+	var se *StreamError
+	if _, err := cs.WriteContext(ctx, []byte("hello")); !errors.As(err, &se) || se.Code != 42 || se.Remote {
+		t.Errorf("want local reset with code 42, got %v", err)
+	}
+
+	// the data received before the reset can be read.
+	buf := make([]byte, 64)
+	n, err := ss.ReadContext(ctx, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Errorf("want %q, got %q", "hello", buf[:n])
+	}
+	if _, err := ss.ReadContext(ctx, buf); !errors.As(err, &se) || se.Code != 42 || !se.Remote {
+		t.Errorf("want remote reset with code 42, got %v", err)
+	}
+	if _, err := ss.WriteContext(ctx, []byte("hello")); !errors.As(err, &se) || se.Code != 42 || !se.Remote {
+		t.Errorf("want remote reset with code 42, got %v", err)
+	}
+}
+
+func TestStream_CloseCancelsPeer(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPair(t, nil)
+
+	cs, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.WriteContext(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	ss, err := server.AcceptContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.ReadContext(ctx, make([]byte, 1)); err != ErrStreamClosed {
+		t.Errorf("want ErrStreamClosed, got %v", err)
+	}
+
+	// the peer reads the data written before Close, and its writes fail.
+	data, err := ctxio.ReadAll(ctx, ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("want %q, got %q", "hello", data)
+	}
+	var se *StreamError
+	waitFor(func() bool {
+		_, err := ss.WriteContext(ctx, []byte("x"))
+		return errors.As(err, &se) && se.Code == CodeCancel && se.Remote
+	})
+}
+
+func TestSession_WriteCancel(t *testing.T) {
+	// the writes of the connection never finish.
+	w := &startedWriter{started: make(chan struct{})}
+	client := Client(ctxiotest.BlockingReader(), w, nil)
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client.CloseContext(ctx)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.OpenContext(ctx)
+		done <- err
+	}()
+	<-w.started
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
+
+// startedWriter closes started when the first write starts, and blocks until ctx is done.
+type startedWriter struct {
+	once    sync.Once
+	started chan struct{}
+}
+
+func (w *startedWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestSession_AcceptCancel(t *testing.T) {
+	_, server := newPair(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := server.AcceptContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSession_AcceptBacklog(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPair(t, &Config{AcceptBacklog: 1})
+
+	cs1, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs2, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var se *StreamError
+	if _, err := cs2.ReadContext(ctx, make([]byte, 1)); !errors.As(err, &se) || se.Code != CodeRefused {
+		t.Errorf("want CodeRefused, got %v", err)
+	}
+	ss, err := server.AcceptContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.ID() != cs1.ID() {
+		t.Errorf("want stream %d, got %d", cs1.ID(), ss.ID())
+	}
+}
+
+func TestSession_Close(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPair(t, nil)
+
+	cs, err := client.OpenContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := server.AcceptContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ss.ReadContext(ctx, make([]byte, 1))
+		done <- err
+	}()
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != ErrSessionClosed {
+		t.Errorf("want ErrSessionClosed, got %v", err)
+	}
+	<-server.Done()
+	if _, err := server.AcceptContext(ctx); err != ErrSessionClosed {
+		t.Errorf("want ErrSessionClosed, got %v", err)
+	}
+	if _, err := client.OpenContext(ctx); err != ErrSessionClosed {
+		t.Errorf("want ErrSessionClosed, got %v", err)
+	}
+	if _, err := cs.WriteContext(ctx, []byte("hello")); err != ErrSessionClosed {
+		t.Errorf("want ErrSessionClosed, got %v", err)
+	}
+}
+
+func TestSession_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	client, server := newPair(t, &Config{MaxFrameSize: 1000})
+
+	// echo server
+	go func() {
+		for {
+			ss, err := server.AcceptContext(ctx)
+			if err != nil {
+				return
+			}
+			go func() {
+				ctxio.Copy(ctx, ss, ss)
+				ss.CloseWrite()
+			}()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cs, err := client.OpenContext(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer cs.Close()
+			want := bytes.Repeat([]byte(fmt.Sprintf("stream %d;", i)), 10000)
+			go func() {
+				cs.WriteContext(ctx, want)
+				cs.CloseWrite()
+			}()
+			got, err := ctxio.ReadAll(ctx, cs)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("stream %d: echo mismatch", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// waitFor yields to the other goroutines until cond holds.
+// A condition that never holds is caught by the test timeout.
+func waitFor(cond func() bool) {
+	for !cond() {
+		runtime.Gosched()
+	}
+}
@@ -0,0 +1,425 @@
+package mux
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/shogo82148/ctxio"
+)
+
+// Session multiplexes streams over a pair of ctxio.Reader and ctxio.Writer.
+// One end of the connection must be created by Client and the other by Server.
+type Session struct {
+	r      ctxio.Reader
+	w      ctxio.Writer
+	config *Config
+
+	// ctx is canceled when the session is terminated,
+	// to interrupt the read and write of the connection.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	writeCh   chan *writeRequest
+	ctrlReady chan struct{}
+	ctrlMu    sync.Mutex
+	ctrl      [][]byte
+
+	acceptCh chan *Stream
+
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	mu         sync.Mutex
+	streams    map[uint32]*Stream
+	nextID     uint32
+	lastRemote uint32
+	done       chan struct{}
+	err        error
+}
+
+type writeRequest struct {
+	h       header
+	payload []byte
+	done    chan error
+
+	// state is reqPending until the write loop starts writing the frame,
+	// or the caller abandons it.
+	state atomic.Int32
+	// copied is closed when the write loop no longer uses payload.
+	copied chan struct{}
+}
+
+const (
+	reqPending int32 = iota
+	reqStarted
+	reqAbandoned
+)
+
+// Client returns a Session for the client side of the connection
+// which reads from r and writes to w.
+// If config is nil, the default configuration is used.
+func Client(r ctxio.Reader, w ctxio.Writer, config *Config) *Session {
+	return newSession(r, w, config, 1)
+}
+
+// Server returns a Session for the server side of the connection
+// which reads from r and writes to w.
+// If config is nil, the default configuration is used.
+func Server(r ctxio.Reader, w ctxio.Writer, config *Config) *Session {
+	return newSession(r, w, config, 2)
+}
+
+func newSession(r ctxio.Reader, w ctxio.Writer, config *Config, firstID uint32) *Session {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Session{
+		r:         r,
+		w:         w,
+		config:    config,
+		ctx:       ctx,
+		cancel:    cancel,
+		writeCh:   make(chan *writeRequest),
+		ctrlReady: make(chan struct{}, 1),
+		acceptCh:  make(chan *Stream, config.acceptBacklog()),
+		closing:   make(chan struct{}),
+		streams:   make(map[uint32]*Stream),
+		nextID:    firstID,
+		done:      make(chan struct{}),
+	}
+	s.wg.Add(2)
+	go s.readLoop()
+	go s.writeLoop()
+	return s
+}
+
+// OpenContext opens a new stream.
+func (s *Session) OpenContext(ctx context.Context) (*Stream, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		err := s.err
+		s.mu.Unlock()
+		return nil, err
+	}
+	id := s.nextID
+	s.nextID += 2
+	st := newStream(s, id)
+	s.streams[id] = st
+	s.mu.Unlock()
+
+	// the SYN frame also tells the peer how much our window exceeds the initial window.
+	var payload [4]byte
+	binary.BigEndian.PutUint32(payload[:], s.config.windowSize()-initialWindow)
+	h := header{typ: frameWindowUpdate, flags: flagSYN, stream: id, length: 4}
+	if queued, err := s.writeFrame(ctx, h, payload[:]); err != nil {
+		if queued {
+			// the peer will see the stream, so tell it that the stream is gone.
+			s.sendReset(id, CodeCancel)
+		}
+		s.removeStream(id)
+		return nil, err
+	}
+	return st, nil
+}
+
+// AcceptContext waits for and returns the next stream opened by the peer.
+func (s *Session) AcceptContext(ctx context.Context) (*Stream, error) {
+	select {
+	case st := <-s.acceptCh:
+		return st, nil
+	default:
+	}
+	select {
+	case st := <-s.acceptCh:
+		return st, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.done:
+		return nil, s.Err()
+	}
+}
+
+// NumStreams returns the number of open streams.
+func (s *Session) NumStreams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.streams)
+}
+
+// Done returns a channel that is closed when the session is terminated.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the reason why the session is terminated, or nil if it is not terminated.
+// It is ErrSessionClosed if the session is closed by either side.
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close closes the session and all of its streams.
+// It is same as CloseContext with context.Background().
+func (s *Session) Close() error {
+	return s.CloseContext(context.Background())
+}
+
+// CloseContext closes the session and all of its streams.
+// It tells the peer that the session is closed, and waits for
+// the goroutines of the session to finish.
+// If ctx is done before the peer is told, the connection is abandoned.
+//
+// CloseContext doesn't close the underlying reader and writer.
+func (s *Session) CloseContext(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		s.terminate(ErrSessionClosed)
+	}
+	s.wg.Wait()
+	return nil
+}
+
+// terminate terminates the session with err.
+func (s *Session) terminate(err error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.err = err
+	close(s.done)
+	streams := make([]*Stream, 0, len(s.streams))
+	for _, st := range s.streams {
+		streams = append(streams, st)
+	}
+	s.mu.Unlock()
+
+	s.cancel()
+	for _, st := range streams {
+		st.wake()
+	}
+}
+
+func (s *Session) removeStream(id uint32) {
+	s.mu.Lock()
+	delete(s.streams, id)
+	s.mu.Unlock()
+}
+
+func (s *Session) stream(id uint32) *Stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[id]
+}
+
+// writeFrame writes a frame through the write loop.
+// If ctx is done before the write loop starts writing the frame, the frame is dropped.
+// Once started, the frame is written in full, or the session is terminated;
+// if ctx is done during the write, writeFrame returns ctx.Err() with queued set,
+// without waiting for the write.
+// payload is not used after writeFrame returns.
+func (s *Session) writeFrame(ctx context.Context, h header, payload []byte) (queued bool, err error) {
+	req := &writeRequest{
+		h:       h,
+		payload: payload,
+		done:    make(chan error, 1),
+		copied:  make(chan struct{}),
+	}
+	select {
+	case s.writeCh <- req:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-s.done:
+		return false, s.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err == nil, err
+	case <-ctx.Done():
+		if req.state.CompareAndSwap(reqPending, reqAbandoned) {
+			return false, ctx.Err()
+		}
+		<-req.copied
+		return true, ctx.Err()
+	case <-s.done:
+		return false, s.Err()
+	}
+}
+
+// queueControl queues a control frame.
+// It never blocks, so it may be called from the read loop.
+func (s *Session) queueControl(h header, payload []byte) {
+	buf := make([]byte, 0, headerSize+len(payload))
+	buf = h.append(buf)
+	buf = append(buf, payload...)
+
+	s.ctrlMu.Lock()
+	s.ctrl = append(s.ctrl, buf)
+	s.ctrlMu.Unlock()
+
+	select {
+	case s.ctrlReady <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Session) sendWindowUpdate(id, n uint32) {
+	var payload [4]byte
+	binary.BigEndian.PutUint32(payload[:], n)
+	s.queueControl(header{typ: frameWindowUpdate, stream: id, length: 4}, payload[:])
+}
+
+func (s *Session) sendReset(id uint32, code ErrorCode) {
+	var payload [4]byte
+	binary.BigEndian.PutUint32(payload[:], uint32(code))
+	s.queueControl(header{typ: frameReset, stream: id, length: 4}, payload[:])
+}
+
+func (s *Session) writeLoop() {
+	defer s.wg.Done()
+	var buf []byte
+	for {
+		var err error
+		select {
+		case <-s.ctrlReady:
+			err = s.flushControl()
+		case req := <-s.writeCh:
+			if !req.state.CompareAndSwap(reqPending, reqStarted) {
+				// the caller has given up.
+				break
+			}
+			buf = req.h.append(buf[:0])
+			buf = append(buf, req.payload...)
+			close(req.copied)
+			_, err = s.w.WriteContext(s.ctx, buf)
+			req.done <- err
+		case <-s.closing:
+			if err := s.flushControl(); err == nil {
+				buf = header{typ: frameGoAway}.append(buf[:0])
+				s.w.WriteContext(s.ctx, buf)
+			}
+			s.terminate(ErrSessionClosed)
+			return
+		case <-s.done:
+			return
+		}
+		if err != nil {
+			s.terminate(err)
+			return
+		}
+	}
+}
+
+func (s *Session) flushControl() error {
+	s.ctrlMu.Lock()
+	ctrl := s.ctrl
+	s.ctrl = nil
+	s.ctrlMu.Unlock()
+
+	for _, buf := range ctrl {
+		if _, err := s.w.WriteContext(s.ctx, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Session) readLoop() {
+	defer s.wg.Done()
+	var hbuf [headerSize]byte
+	for {
+		if _, err := ctxio.ReadFull(s.ctx, s.r, hbuf[:]); err != nil {
+			if err == io.EOF {
+				err = ErrSessionClosed
+			}
+			s.terminate(err)
+			return
+		}
+		h := parseHeader(hbuf[:])
+		if h.length > maxPayload {
+			s.terminate(ErrProtocol)
+			return
+		}
+		payload := make([]byte, h.length)
+		if _, err := ctxio.ReadFull(s.ctx, s.r, payload); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			s.terminate(err)
+			return
+		}
+		if err := s.handleFrame(h, payload); err != nil {
+			s.terminate(err)
+			return
+		}
+	}
+}
+
+func (s *Session) handleFrame(h header, payload []byte) error {
+	switch h.typ {
+	case frameData:
+		st := s.stream(h.stream)
+		if st == nil {
+			// the stream is already closed locally; drop the data.
+			return nil
+		}
+		st.receive(payload, h.flags&flagFIN != 0)
+	case frameWindowUpdate:
+		if len(payload) != 4 {
+			return ErrProtocol
+		}
+		n := binary.BigEndian.Uint32(payload)
+		if h.flags&flagSYN != 0 {
+			return s.accept(h.stream, n)
+		}
+		if st := s.stream(h.stream); st != nil {
+			st.addSendWindow(n)
+		}
+	case frameReset:
+		if len(payload) != 4 {
+			return ErrProtocol
+		}
+		if st := s.stream(h.stream); st != nil {
+			st.reset(&StreamError{Code: ErrorCode(binary.BigEndian.Uint32(payload)), Remote: true})
+		}
+	case frameGoAway:
+		return ErrSessionClosed
+	default:
+		return ErrProtocol
+	}
+	return nil
+}
+
+// accept registers a stream opened by the peer.
+func (s *Session) accept(id, window uint32) error {
+	s.mu.Lock()
+	// the IDs of the streams opened by the peer have the other parity, and increase.
+	if id%2 == s.nextID%2 || id <= s.lastRemote {
+		s.mu.Unlock()
+		return ErrProtocol
+	}
+	s.lastRemote = id
+	st := newStream(s, id)
+	st.sendWindow += window
+	s.streams[id] = st
+	s.mu.Unlock()
+
+	select {
+	case s.acceptCh <- st:
+	default:
+		s.removeStream(id)
+		s.sendReset(id, CodeRefused)
+		return nil
+	}
+	if n := s.config.windowSize() - initialWindow; n > 0 {
+		s.sendWindowUpdate(id, n)
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package mux
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Stream is a bidirectional stream of a Session.
+// It implements ctxio.Reader, ctxio.Writer and io.Closer.
+type Stream struct {
+	id   uint32
+	sess *Session
+
+	mu     sync.Mutex
+	event  notifier
+	buf    []byte
+	window uint32 // how much more the peer may send
+	unack  uint32 // bytes read but not announced to the peer yet
+
+	sendWindow uint32
+
+	finRecv    bool
+	finSent    bool
+	readClosed bool
+	err        error // set by Reset
+}
+
+func newStream(s *Session, id uint32) *Stream {
+	return &Stream{
+		id:         id,
+		sess:       s,
+		window:     s.config.windowSize(),
+		sendWindow: initialWindow,
+	}
+}
+
+// ID returns the ID of the stream.
+// The streams opened by the client have odd IDs,
+// and the streams opened by the server have even IDs.
+func (st *Stream) ID() uint32 {
+	return st.id
+}
+
+// ReadContext reads data sent by the peer.
+// It returns io.EOF after the peer calls CloseWrite or Close.
+// The data received before the peer resets the stream can be still read,
+// but Reset discards the data not read yet.
+func (st *Stream) ReadContext(ctx context.Context, data []byte) (int, error) {
+	st.mu.Lock()
+	for {
+		if st.readClosed {
+			st.mu.Unlock()
+			return 0, ErrStreamClosed
+		}
+		if se, ok := st.err.(*StreamError); ok && !se.Remote {
+			st.mu.Unlock()
+			return 0, st.err
+		}
+		if len(st.buf) > 0 {
+			break
+		}
+		if st.finRecv {
+			st.mu.Unlock()
+			return 0, io.EOF
+		}
+		if err := st.errLocked(); err != nil {
+			st.mu.Unlock()
+			return 0, err
+		}
+		if len(data) == 0 {
+			st.mu.Unlock()
+			return 0, nil
+		}
+		if err := st.waitLocked(ctx); err != nil {
+			st.mu.Unlock()
+			return 0, err
+		}
+	}
+
+	n := copy(data, st.buf)
+	st.buf = st.buf[n:]
+	if len(st.buf) == 0 {
+		st.buf = nil
+	}
+
+	// announce the free space of the window when a half of the window is read.
+	var update uint32
+	st.unack += uint32(n)
+	if !st.finRecv && st.err == nil && st.unack >= st.sess.config.windowSize()/2 {
+		update = st.unack
+		st.window += update
+		st.unack = 0
+	}
+	st.mu.Unlock()
+
+	if update > 0 {
+		st.sess.sendWindowUpdate(st.id, update)
+	}
+	return n, nil
+}
+
+// WriteContext writes data to the stream.
+// It blocks while the flow control window of the peer is full.
+//
+// Each frame handed to the session is written in full, even if ctx is done
+// while it is being written; n counts the frames handed to the session.
+func (st *Stream) WriteContext(ctx context.Context, data []byte) (n int, err error) {
+	maxFrame := st.sess.config.maxFrameSize()
+	for n < len(data) {
+		st.mu.Lock()
+		for {
+			if st.finSent {
+				st.mu.Unlock()
+				return n, ErrWriteClosed
+			}
+			if err := st.errLocked(); err != nil {
+				st.mu.Unlock()
+				return n, err
+			}
+			if st.sendWindow > 0 {
+				break
+			}
+			if err := st.waitLocked(ctx); err != nil {
+				st.mu.Unlock()
+				return n, err
+			}
+		}
+		m := uint32(len(data) - n)
+		if m > st.sendWindow {
+			m = st.sendWindow
+		}
+		if m > maxFrame {
+			m = maxFrame
+		}
+		st.sendWindow -= m
+		st.mu.Unlock()
+
+		h := header{typ: frameData, stream: st.id, length: m}
+		if queued, err := st.sess.writeFrame(ctx, h, data[n:n+int(m)]); err != nil {
+			if queued {
+				n += int(m)
+			} else {
+				st.addSendWindow(m)
+			}
+			return n, err
+		}
+		n += int(m)
+	}
+	return n, nil
+}
+
+// CloseWrite half-closes the stream;
+// the peer reads io.EOF after the data written so far.
+func (st *Stream) CloseWrite() error {
+	return st.closeWrite(context.Background())
+}
+
+func (st *Stream) closeWrite(ctx context.Context) error {
+	st.mu.Lock()
+	if st.finSent {
+		st.mu.Unlock()
+		return nil
+	}
+	if err := st.errLocked(); err != nil {
+		st.mu.Unlock()
+		return err
+	}
+	st.finSent = true
+	st.mu.Unlock()
+
+	h := header{typ: frameData, flags: flagFIN, stream: st.id}
+	if queued, err := st.sess.writeFrame(ctx, h, nil); err != nil {
+		if queued {
+			st.removeIfDone()
+		}
+		return err
+	}
+	st.removeIfDone()
+	return nil
+}
+
+// Close closes the stream.
+// It half-closes the stream as CloseWrite does, and stops reading.
+// If the peer hasn't finished sending, the stream is reset with CodeCancel,
+// after the data written so far is delivered.
+func (st *Stream) Close() error {
+	err := st.closeWrite(context.Background())
+
+	st.mu.Lock()
+	if st.readClosed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.readClosed = true
+	st.buf = nil
+	cancel := !st.finRecv && st.err == nil
+	if cancel {
+		st.err = &StreamError{Code: CodeCancel}
+	}
+	st.event.broadcast()
+	st.mu.Unlock()
+
+	if cancel {
+		st.sess.sendReset(st.id, CodeCancel)
+	}
+	st.sess.removeStream(st.id)
+	if _, ok := err.(*StreamError); ok {
+		// the stream is already reset; nothing left to close.
+		return nil
+	}
+	return err
+}
+
+// Reset aborts the stream with code.
+// The pending and future operations on both ends fail with a *StreamError.
+func (st *Stream) Reset(code ErrorCode) error {
+	st.mu.Lock()
+	if st.err != nil {
+		st.mu.Unlock()
+		return nil
+	}
+	st.err = &StreamError{Code: code}
+	st.event.broadcast()
+	st.mu.Unlock()
+
+	st.sess.sendReset(st.id, code)
+	st.sess.removeStream(st.id)
+	return nil
+}
+
+// errLocked returns the error which fails the operations on the stream.
+// st.mu must be held.
+func (st *Stream) errLocked() error {
+	if st.err != nil {
+		return st.err
+	}
+	select {
+	case <-st.sess.done:
+		return st.sess.Err()
+	default:
+	}
+	return nil
+}
+
+// waitLocked waits for an event on the stream.
+// st.mu must be held; it is released while waiting.
+func (st *Stream) waitLocked(ctx context.Context) error {
+	ch := st.event.wait()
+	st.mu.Unlock()
+	defer st.mu.Lock()
+	select {
+	case <-ch:
+		return nil
+	case <-st.sess.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (st *Stream) wake() {
+	st.mu.Lock()
+	st.event.broadcast()
+	st.mu.Unlock()
+}
+
+// receive is called by the read loop when a data frame arrives.
+func (st *Stream) receive(data []byte, fin bool) {
+	st.mu.Lock()
+	if st.finRecv || st.readClosed || st.err != nil {
+		st.mu.Unlock()
+		return
+	}
+	if uint32(len(data)) > st.window {
+		st.err = &StreamError{Code: CodeFlowControl}
+		st.event.broadcast()
+		st.mu.Unlock()
+		st.sess.sendReset(st.id, CodeFlowControl)
+		st.sess.removeStream(st.id)
+		return
+	}
+	st.window -= uint32(len(data))
+	st.buf = append(st.buf, data...)
+	st.finRecv = fin
+	st.event.broadcast()
+	st.mu.Unlock()
+
+	if fin {
+		st.removeIfDone()
+	}
+}
+
+func (st *Stream) addSendWindow(n uint32) {
+	st.mu.Lock()
+	st.sendWindow += n
+	st.event.broadcast()
+	st.mu.Unlock()
+}
+
+// reset is called by the read loop when the peer resets the stream.
+func (st *Stream) reset(err error) {
+	st.mu.Lock()
+	if st.err == nil {
+		st.err = err
+	}
+	st.event.broadcast()
+	st.mu.Unlock()
+	st.sess.removeStream(st.id)
+}
+
+// removeIfDone removes the stream from the session if both directions are closed.
+func (st *Stream) removeIfDone() {
+	st.mu.Lock()
+	done := st.finSent && st.finRecv
+	st.mu.Unlock()
+	if done {
+		st.sess.removeStream(st.id)
+	}
+}
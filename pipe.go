@@ -3,7 +3,10 @@ package ctxio
 import (
 	"context"
 	"io"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // onceError is an object that will only store an error once.
@@ -12,13 +15,15 @@ type onceError struct {
 	err        error
 }
 
-func (a *onceError) Store(err error) {
+// Store stores err if no error is stored, and reports whether err is stored.
+func (a *onceError) Store(err error) bool {
 	a.Lock()
 	defer a.Unlock()
 	if a.err != nil {
-		return
+		return false
 	}
 	a.err = err
+	return true
 }
 func (a *onceError) Load() error {
 	a.Lock()
@@ -35,9 +40,102 @@ type pipe struct {
 	done chan struct{}
 	rerr onceError
 	werr onceError
+
+	hooks  PipeHooks
+	bytes  atomic.Int64
+	reads  atomic.Int64
+	writes atomic.Int64
+
+	readCalls  pipeCalls
+	writeCalls pipeCalls
+}
+
+// pipeCalls counts the calls of read or write of a pipe without locking,
+// so that the calls don't allocate nor contend on a lock.
+type pipeCalls struct {
+	// entered and exited count the updates of the fields below,
+	// so that snapshot can tell that no update overlapped its loads.
+	entered atomic.Int64
+	exited  atomic.Int64
+
+	pending atomic.Int64
+	starts  atomic.Int64 // the sum of the start times of the pending calls in Unix nanoseconds; it may wrap around
+	blocked atomic.Int64 // the time spent by the finished calls in nanoseconds
+
+	// clock is the clock of the last call, which measures the time spent by the pending calls.
+	clock atomic.Pointer[Clock]
+}
+
+// systemClockRef is stored in pipeCalls.clock for SystemClock, not to allocate a pointer for each call.
+var systemClockRef = func() *Clock {
+	var c Clock = systemClock{}
+	return &c
+}()
+
+// begin counts a call in progress, and returns its clock and its start time.
+func (c *pipeCalls) begin(ctx context.Context) (Clock, int64) {
+	clock := ClockFromContext(ctx)
+	ref := systemClockRef
+	if !isSystemClock(clock) {
+		other := clock // escapes only in this branch
+		ref = &other
+	}
+	start := clock.Now().UnixNano()
+
+	c.entered.Add(1)
+	c.clock.Store(ref)
+	c.pending.Add(1)
+	c.starts.Add(start)
+	c.exited.Add(1)
+	return clock, start
+}
+
+// end counts the call started at start as finished.
+func (c *pipeCalls) end(clock Clock, start int64) {
+	now := clock.Now().UnixNano()
+
+	c.entered.Add(1)
+	c.pending.Add(-1)
+	c.starts.Add(-start)
+	c.blocked.Add(now - start)
+	c.exited.Add(1)
+}
+
+// snapshot returns the number of the pending calls,
+// and the time spent by all calls including the pending ones.
+func (c *pipeCalls) snapshot() (pending int, blocked time.Duration) {
+	for {
+		exited := c.exited.Load()
+		n := c.pending.Load()
+		starts := c.starts.Load()
+		d := c.blocked.Load()
+		clock := c.clock.Load()
+		if c.entered.Load() != exited {
+			// a call updated the fields while loading them.
+			runtime.Gosched()
+			continue
+		}
+		if n > 0 {
+			// the wrap around of starts cancels out, as long as the result fits.
+			d += n*(*clock).Now().UnixNano() - starts
+		}
+		return int(n), time.Duration(d)
+	}
 }
 
 func (p *pipe) read(ctx context.Context, b []byte) (int, error) {
+	clock, start := p.readCalls.begin(ctx)
+	n, err := p.receive(ctx, b)
+	p.readCalls.end(clock, start)
+	p.reads.Add(1)
+	p.bytes.Add(int64(n))
+	if p.hooks.OnRead != nil {
+		p.hooks.OnRead(n, err)
+	}
+	return n, err
+}
+
+func (p *pipe) receive(ctx context.Context, b []byte) (int, error) {
 	select {
 	case <-p.done:
 		return 0, p.readCloseError()
@@ -66,12 +164,26 @@ func (p *pipe) closeRead(err error) error {
 	if err == nil {
 		err = io.ErrClosedPipe
 	}
-	p.rerr.Store(err)
+	stored := p.rerr.Store(err)
 	p.once.Do(func() { close(p.done) })
+	if stored && p.hooks.OnCloseRead != nil {
+		p.hooks.OnCloseRead(err)
+	}
 	return nil
 }
 
-func (p *pipe) write(ctx context.Context, b []byte) (n int, err error) {
+func (p *pipe) write(ctx context.Context, b []byte) (int, error) {
+	clock, start := p.writeCalls.begin(ctx)
+	n, err := p.send(ctx, b)
+	p.writeCalls.end(clock, start)
+	p.writes.Add(1)
+	if p.hooks.OnWrite != nil {
+		p.hooks.OnWrite(n, err)
+	}
+	return n, err
+}
+
+func (p *pipe) send(ctx context.Context, b []byte) (n int, err error) {
 	select {
 	case <-p.done:
 		return 0, p.writeCloseError()
@@ -93,7 +205,7 @@ func (p *pipe) write(ctx context.Context, b []byte) (n int, err error) {
 			b = b[nw:]
 			n += nw
 		case <-ctx.Done():
-			return 0, ctx.Err()
+			return n, ctx.Err()
 		case <-p.done:
 			return n, p.writeCloseError()
 		}
@@ -105,11 +217,33 @@ func (p *pipe) closeWrite(err error) error {
 	if err == nil {
 		err = io.EOF
 	}
-	p.werr.Store(err)
+	stored := p.werr.Store(err)
 	p.once.Do(func() { close(p.done) })
+	if stored && p.hooks.OnCloseWrite != nil {
+		p.hooks.OnCloseWrite(err)
+	}
 	return nil
 }
 
+func (p *pipe) stats() PipeStats {
+	rerr, werr := p.rerr.Load(), p.werr.Load()
+	pendingReads, readBlocked := p.readCalls.snapshot()
+	pendingWrites, writeBlocked := p.writeCalls.snapshot()
+	return PipeStats{
+		Bytes:         p.bytes.Load(),
+		Reads:         p.reads.Load(),
+		Writes:        p.writes.Load(),
+		PendingReads:  pendingReads,
+		PendingWrites: pendingWrites,
+		ReadBlocked:   readBlocked,
+		WriteBlocked:  writeBlocked,
+		ReadClosed:    rerr != nil,
+		WriteClosed:   werr != nil,
+		ReadCloseErr:  rerr,
+		WriteCloseErr: werr,
+	}
+}
+
 // readCloseError is considered internal to the pipe type.
 func (p *pipe) readCloseError() error {
 	rerr := p.rerr.Load()
@@ -128,6 +262,53 @@ func (p *pipe) writeCloseError() error {
 	return io.ErrClosedPipe
 }
 
+// PipeStats is a snapshot of the statistics of a pipe.
+type PipeStats struct {
+	// Bytes is the number of bytes transferred from the writer to the reader.
+	Bytes int64
+
+	// Reads and Writes are the numbers of the finished calls of ReadContext and WriteContext.
+	Reads  int64
+	Writes int64
+
+	// PendingReads and PendingWrites are the numbers of the calls of ReadContext and WriteContext
+	// in progress, such as the ones blocked waiting for the other side.
+	PendingReads  int
+	PendingWrites int
+
+	// ReadBlocked and WriteBlocked are the total time spent in ReadContext and WriteContext,
+	// waiting for the other side, including the time spent so far by the calls in progress.
+	// They are measured by the clock carried by the context of each call.
+	ReadBlocked  time.Duration
+	WriteBlocked time.Duration
+
+	// ReadClosed and WriteClosed report whether the read half and the write half are closed.
+	ReadClosed  bool
+	WriteClosed bool
+
+	// ReadCloseErr and WriteCloseErr are the errors stored by the first CloseWithError of each half.
+	// Close stores io.ErrClosedPipe for the read half and io.EOF for the write half.
+	ReadCloseErr  error
+	WriteCloseErr error
+}
+
+// PipeHooks are the functions called on the operations of a pipe, such as for feeding metrics.
+// The hooks that are nil are not called.
+// They are called synchronously after the operations, and may be called concurrently.
+type PipeHooks struct {
+	// OnRead is called after each ReadContext with its results.
+	OnRead func(n int, err error)
+
+	// OnWrite is called after each WriteContext with its results.
+	OnWrite func(n int, err error)
+
+	// OnCloseRead and OnCloseWrite are called when the read half and the write half are closed,
+	// with the error stored. They are called only once.
+	OnCloseRead  func(err error)
+	OnCloseWrite func(err error)
+}
+
+// A PipeReader is the read half of a pipe.
 type PipeReader struct {
 	p *pipe
 }
@@ -151,11 +332,19 @@ func (r *PipeReader) CloseWithError(err error) error {
 	return r.p.closeRead(err)
 }
 
+// Stats returns the statistics of the pipe.
+// It is the same as the Stats of the write half.
+func (r *PipeReader) Stats() PipeStats {
+	return r.p.stats()
+}
+
 // A PipeWriter is the write half of a pipe.
 type PipeWriter struct {
 	p *pipe
 }
 
+// WriteContext writes data to the pipe, blocking until the readers have consumed all of it.
+// If ctx is done first, it returns the error of ctx with the number of bytes already consumed.
 func (w *PipeWriter) WriteContext(ctx context.Context, data []byte) (n int, err error) {
 	return w.p.write(ctx, data)
 }
@@ -176,11 +365,23 @@ func (w *PipeWriter) CloseWithError(err error) error {
 	return w.p.closeWrite(err)
 }
 
+// Stats returns the statistics of the pipe.
+// It is the same as the Stats of the read half.
+func (w *PipeWriter) Stats() PipeStats {
+	return w.p.stats()
+}
+
 func Pipe() (*PipeReader, *PipeWriter) {
+	return PipeWithHooks(PipeHooks{})
+}
+
+// PipeWithHooks is like Pipe, but calls hooks on the operations of the pipe.
+func PipeWithHooks(hooks PipeHooks) (*PipeReader, *PipeWriter) {
 	p := &pipe{
-		wrCh: make(chan []byte),
-		rdCh: make(chan int),
-		done: make(chan struct{}),
+		wrCh:  make(chan []byte),
+		rdCh:  make(chan int),
+		done:  make(chan struct{}),
+		hooks: hooks,
 	}
 	return &PipeReader{p}, &PipeWriter{p}
 }
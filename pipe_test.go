@@ -1,4 +1,4 @@
-package ctxio
+package ctxio_test
 
 import (
 	"bytes"
@@ -6,13 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
 )
 
-func checkWrite(t *testing.T, w Writer, data []byte, c chan int) {
+var errTest = errors.New("test")
+
+func checkWrite(t *testing.T, w ctxio.Writer, data []byte, c chan int) {
 	n, err := w.WriteContext(context.Background(), data)
 	if err != nil {
 		t.Errorf("write: %v", err)
@@ -26,7 +33,7 @@ func checkWrite(t *testing.T, w Writer, data []byte, c chan int) {
 // Test a single read/write pair.
 func TestPipe1(t *testing.T) {
 	c := make(chan int)
-	r, w := Pipe()
+	r, w := ctxio.Pipe()
 	var buf = make([]byte, 64)
 	go checkWrite(t, w, []byte("hello, world"), c)
 	n, err := r.ReadContext(context.Background(), buf)
@@ -40,7 +47,7 @@ func TestPipe1(t *testing.T) {
 	w.Close()
 }
 
-func reader(t *testing.T, r Reader, c chan int) {
+func reader(t *testing.T, r ctxio.Reader, c chan int) {
 	var buf = make([]byte, 64)
 	for {
 		n, err := r.ReadContext(context.Background(), buf)
@@ -58,7 +65,7 @@ func reader(t *testing.T, r Reader, c chan int) {
 // Test a sequence of read/write pairs.
 func TestPipe2(t *testing.T) {
 	c := make(chan int)
-	r, w := Pipe()
+	r, w := ctxio.Pipe()
 	go reader(t, r, c)
 	var buf = make([]byte, 64)
 	for i := 0; i < 5; i++ {
@@ -88,7 +95,7 @@ type pipeReturn struct {
 }
 
 // Test a large write that requires multiple reads to satisfy.
-func writer(w WriteCloser, buf []byte, c chan pipeReturn) {
+func writer(w ctxio.WriteCloser, buf []byte, c chan pipeReturn) {
 	n, err := w.WriteContext(context.Background(), buf)
 	w.Close()
 	c <- pipeReturn{n, err}
@@ -96,7 +103,7 @@ func writer(w WriteCloser, buf []byte, c chan pipeReturn) {
 
 func TestPipe3(t *testing.T) {
 	c := make(chan pipeReturn)
-	r, w := Pipe()
+	r, w := ctxio.Pipe()
 	var wdat = make([]byte, 128)
 	for i := 0; i < len(wdat); i++ {
 		wdat[i] = byte(i)
@@ -165,8 +172,9 @@ var pipeTests = []pipeTest{
 	{false, io.ErrShortWrite, true},
 }
 
+// delayClose closes cl. In the async tests, it races with the blocked call,
+// which must see the same result whether the close happens before or during the call.
 func delayClose(t *testing.T, cl closer, ch chan int, tt pipeTest) {
-	time.Sleep(1 * time.Millisecond)
 	var err error
 	if tt.closeWithError {
 		err = cl.CloseWithError(tt.err)
@@ -182,7 +190,7 @@ func delayClose(t *testing.T, cl closer, ch chan int, tt pipeTest) {
 func TestPipeReadClose(t *testing.T) {
 	for _, tt := range pipeTests {
 		c := make(chan int, 1)
-		r, w := Pipe()
+		r, w := ctxio.Pipe()
 		if tt.async {
 			go delayClose(t, w, c, tt)
 		} else {
@@ -210,7 +218,7 @@ func TestPipeReadClose(t *testing.T) {
 // Test close on Read side during Read.
 func TestPipeReadClose2(t *testing.T) {
 	c := make(chan int, 1)
-	r, _ := Pipe()
+	r, _ := ctxio.Pipe()
 	go delayClose(t, r, c, pipeTest{})
 	n, err := r.ReadContext(context.Background(), make([]byte, 64))
 	<-c
@@ -224,13 +232,13 @@ func TestPipeReadClose2(t *testing.T) {
 func TestPipeWriteClose(t *testing.T) {
 	for _, tt := range pipeTests {
 		c := make(chan int, 1)
-		r, w := Pipe()
+		r, w := ctxio.Pipe()
 		if tt.async {
 			go delayClose(t, r, c, tt)
 		} else {
 			delayClose(t, r, c, tt)
 		}
-		n, err := WriteStringContext(context.Background(), w, "hello, world")
+		n, err := ctxio.WriteStringContext(context.Background(), w, "hello, world")
 		<-c
 		expect := tt.err
 		if expect == nil {
@@ -251,7 +259,7 @@ func TestPipeWriteClose(t *testing.T) {
 // Test close on Write side during Write.
 func TestPipeWriteClose2(t *testing.T) {
 	c := make(chan int, 1)
-	_, w := Pipe()
+	_, w := ctxio.Pipe()
 	go delayClose(t, w, c, pipeTest{})
 	n, err := w.WriteContext(context.Background(), make([]byte, 64))
 	<-c
@@ -261,18 +269,18 @@ func TestPipeWriteClose2(t *testing.T) {
 }
 
 func TestWriteNil(t *testing.T) {
-	r, w := Pipe()
+	r, w := ctxio.Pipe()
 	go func() {
 		w.WriteContext(context.Background(), nil)
 		w.Close()
 	}()
 	var b [2]byte
-	ReadFull(context.Background(), r, b[0:2])
+	ctxio.ReadFull(context.Background(), r, b[0:2])
 	r.Close()
 }
 
 func TestWriteAfterWriterClose(t *testing.T) {
-	r, w := Pipe()
+	r, w := ctxio.Pipe()
 
 	done := make(chan bool)
 	var writeErr error
@@ -288,7 +296,7 @@ func TestWriteAfterWriterClose(t *testing.T) {
 
 	buf := make([]byte, 100)
 	var result string
-	n, err := ReadFull(context.Background(), r, buf)
+	n, err := ctxio.ReadFull(context.Background(), r, buf)
 	if err != nil && err != io.ErrUnexpectedEOF {
 		t.Fatalf("got: %q; want: %q", err, io.ErrUnexpectedEOF)
 	}
@@ -307,7 +315,7 @@ func TestPipeCloseError(t *testing.T) {
 	type testError1 struct{ error }
 	type testError2 struct{ error }
 
-	r, w := Pipe()
+	r, w := ctxio.Pipe()
 	r.CloseWithError(testError1{})
 	if _, err := w.WriteContext(context.Background(), nil); err != (testError1{}) {
 		t.Errorf("Write error: got %T, want testError1", err)
@@ -317,7 +325,7 @@ func TestPipeCloseError(t *testing.T) {
 		t.Errorf("Write error: got %T, want testError1", err)
 	}
 
-	r, w = Pipe()
+	r, w = ctxio.Pipe()
 	w.CloseWithError(testError1{})
 	if _, err := r.ReadContext(context.Background(), nil); err != (testError1{}) {
 		t.Errorf("Read error: got %T, want testError1", err)
@@ -336,16 +344,19 @@ func TestPipeConcurrent(t *testing.T) {
 	)
 
 	t.Run("Write", func(t *testing.T) {
-		r, w := Pipe()
+		r, w := ctxio.Pipe()
 
+		// start all the writers at once to increase probability of race.
+		start := make(chan struct{})
 		for i := 0; i < count; i++ {
 			go func() {
-				time.Sleep(time.Millisecond) // Increase probability of race
+				<-start
 				if n, err := w.WriteContext(context.Background(), []byte(input)); n != len(input) || err != nil {
 					t.Errorf("Write() = (%d, %v); want (%d, nil)", n, err, len(input))
 				}
 			}()
 		}
+		close(start)
 
 		buf := make([]byte, count*len(input))
 		for i := 0; i < len(buf); i += readSize {
@@ -364,12 +375,13 @@ func TestPipeConcurrent(t *testing.T) {
 	})
 
 	t.Run("Read", func(t *testing.T) {
-		r, w := Pipe()
+		r, w := ctxio.Pipe()
 
 		c := make(chan []byte, count*len(input)/readSize)
+		start := make(chan struct{})
 		for i := 0; i < cap(c); i++ {
 			go func() {
-				time.Sleep(time.Millisecond) // Increase probability of race
+				<-start
 				buf := make([]byte, readSize)
 				if n, err := r.ReadContext(context.Background(), buf); n != readSize || err != nil {
 					t.Errorf("Read() = (%d, %v); want (%d, nil)", n, err, readSize)
@@ -377,6 +389,7 @@ func TestPipeConcurrent(t *testing.T) {
 				c <- buf
 			}()
 		}
+		close(start)
 
 		for i := 0; i < count; i++ {
 			if n, err := w.WriteContext(context.Background(), []byte(input)); n != len(input) || err != nil {
@@ -410,24 +423,221 @@ func sortBytesInGroups(b []byte, n int) []byte {
 }
 
 func TestPipeContext(t *testing.T) {
-	r, w := Pipe()
-	var ctx context.Context
-	var cancel context.CancelFunc
+	clock := ctxiotest.NewFakeClock(epoch)
+	r, w := ctxio.Pipe()
+	defer r.Close()
+	defer w.Close()
 
 	// read timeout
-	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	done := make(chan error, 1)
+	ctx, cancel := clock.WithTimeout(context.Background(), time.Second)
 	defer cancel()
-	if _, err := r.ReadContext(ctx, make([]byte, 16)); !errors.Is(err, context.DeadlineExceeded) {
+	go func() {
+		_, err := r.ReadContext(ctx, make([]byte, 16))
+		done <- err
+	}()
+	clock.Advance(time.Second)
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
 		t.Errorf("want context.DeadlineExceeded, got %v", err)
 	}
 
 	// write timeout
-	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	ctx, cancel = clock.WithTimeout(context.Background(), time.Second)
 	defer cancel()
-	if _, err := w.WriteContext(ctx, make([]byte, 16)); !errors.Is(err, context.DeadlineExceeded) {
+	go func() {
+		_, err := w.WriteContext(ctx, make([]byte, 16))
+		done <- err
+	}()
+	clock.Advance(time.Second)
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
 		t.Errorf("want context.DeadlineExceeded, got %v", err)
 	}
+}
+
+func TestPipeContext_PartialWrite(t *testing.T) {
+	r, w := ctxio.Pipe()
+	defer r.Close()
+	defer w.Close()
+
+	// the reader consumes a part of the write, and then the write is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		buf := make([]byte, 5)
+		if _, err := ctxio.ReadFull(context.Background(), r, buf); err == nil {
+			cancel()
+		}
+	}()
+	n, err := w.WriteContext(ctx, []byte("hello, world"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if n != 5 {
+		t.Errorf("want 5 bytes written, got %d", n)
+	}
+}
+
+// steppingClock advances by a second every time Now is called.
+type steppingClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *steppingClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+func (c *steppingClock) NewTimer(d time.Duration) ctxio.Timer {
+	return ctxio.SystemClock.NewTimer(d)
+}
+
+func (c *steppingClock) AfterFunc(d time.Duration, f func()) ctxio.Timer {
+	return ctxio.SystemClock.AfterFunc(d, f)
+}
+
+func TestPipeStats(t *testing.T) {
+	// each call is blocked for a second.
+	rctx := ctxio.WithClock(context.Background(), &steppingClock{})
+	wctx := ctxio.WithClock(context.Background(), &steppingClock{})
+	r, w := ctxio.Pipe()
+
+	go func() {
+		w.WriteContext(wctx, []byte("hello, "))
+		w.WriteContext(wctx, []byte("world"))
+		w.CloseWithError(errTest)
+	}()
+	data, err := ctxio.ReadAll(rctx, r)
+	if !errors.Is(err, errTest) {
+		t.Errorf("want errTest, got %v", err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+
+	stats := w.Stats()
+	if stats.Bytes != 12 {
+		t.Errorf("want 12 bytes, got %d", stats.Bytes)
+	}
+	if stats.Reads != 3 || stats.Writes != 2 {
+		t.Errorf("want 3 reads and 2 writes, got %d and %d", stats.Reads, stats.Writes)
+	}
+	if stats.ReadBlocked != 3*time.Second || stats.WriteBlocked != 2*time.Second {
+		t.Errorf("want 3s and 2s blocked, got %v and %v", stats.ReadBlocked, stats.WriteBlocked)
+	}
+	if stats.ReadClosed || !stats.WriteClosed {
+		t.Errorf("want only the write half closed, got %v and %v", stats.ReadClosed, stats.WriteClosed)
+	}
+	if stats.WriteCloseErr != errTest {
+		t.Errorf("want errTest, got %v", stats.WriteCloseErr)
+	}
 
+	r.Close()
+	stats = r.Stats()
+	if !stats.ReadClosed || stats.ReadCloseErr != io.ErrClosedPipe {
+		t.Errorf("want the read half closed with io.ErrClosedPipe, got %v and %v", stats.ReadClosed, stats.ReadCloseErr)
+	}
+}
+
+func TestPipeStats_Pending(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := ctxio.WithClock(context.Background(), clock)
+	r, w := ctxio.Pipe()
+	defer r.Close()
+	defer w.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.ReadContext(ctx, make([]byte, 16))
+	}()
+	for r.Stats().PendingReads == 0 {
+		runtime.Gosched()
+	}
+
+	// the blocked read is counted while it is in progress.
+	clock.Advance(5 * time.Second)
+	stats := r.Stats()
+	if stats.PendingReads != 1 || stats.Reads != 0 {
+		t.Errorf("want 1 pending read and no finished reads, got %d and %d", stats.PendingReads, stats.Reads)
+	}
+	if stats.ReadBlocked != 5*time.Second {
+		t.Errorf("want 5s blocked, got %v", stats.ReadBlocked)
+	}
+
+	if _, err := w.WriteContext(context.Background(), []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	stats = r.Stats()
+	if stats.PendingReads != 0 || stats.Reads != 1 {
+		t.Errorf("want no pending reads and 1 finished read, got %d and %d", stats.PendingReads, stats.Reads)
+	}
+	if stats.ReadBlocked != 5*time.Second {
+		t.Errorf("want 5s blocked, got %v", stats.ReadBlocked)
+	}
+}
+
+func BenchmarkPipe_SmallWrites(b *testing.B) {
+	ctx := context.Background()
+	r, w := ctxio.Pipe()
+	defer r.Close()
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			if _, err := r.ReadContext(ctx, buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	data := []byte("hello")
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := w.WriteContext(ctx, data); err != nil {
+			b.Fatal(err)
+		}
+	}
 	w.Close()
+}
+
+func TestPipeWithHooks(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf(format, args...))
+	}
+	r, w := ctxio.PipeWithHooks(ctxio.PipeHooks{
+		OnRead:       func(n int, err error) { record("read %d %v", n, err) },
+		OnWrite:      func(n int, err error) { record("write %d %v", n, err) },
+		OnCloseRead:  func(err error) { record("close read %v", err) },
+		OnCloseWrite: func(err error) { record("close write %v", err) },
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.WriteContext(context.Background(), []byte("hello"))
+		w.Close()
+		w.CloseWithError(errTest) // ignored
+	}()
+	buf := make([]byte, 16)
+	r.ReadContext(context.Background(), buf)
+	<-done
+	r.ReadContext(context.Background(), buf)
 	r.Close()
+
+	want := []string{"close read io: read/write on closed pipe", "close write EOF", "read 0 EOF", "read 5 <nil>", "write 5 <nil>"}
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(events)
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want events %q, got %q", want, events)
+	}
 }
@@ -0,0 +1,282 @@
+package ctxio
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CountingReader is a Reader that counts the bytes read from the underlying Reader.
+// The count may be read concurrently with reads.
+type CountingReader struct {
+	r Reader
+	n atomic.Int64
+
+	// fn is called with the total after each transfer, if not nil.
+	fn func(n int64)
+}
+
+var _ WriterTo = (*CountingReader)(nil)
+
+// NewCountingReader returns a CountingReader that reads from r.
+func NewCountingReader(r Reader) *CountingReader {
+	return &CountingReader{r: r}
+}
+
+func (r *CountingReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	n, err := r.r.ReadContext(ctx, data)
+	r.add(n)
+	return n, err
+}
+
+// WriteToContext implements WriterTo.
+// It uses the WriterTo of the underlying Reader if available.
+func (r *CountingReader) WriteToContext(ctx context.Context, w Writer) (int64, error) {
+	if wt, ok := r.r.(WriterTo); ok {
+		return wt.WriteToContext(ctx, &countWriter{w: w, add: r.add})
+	}
+	return copyBuffer(ctx, w, readerOnly{r}, nil)
+}
+
+// Count returns the number of bytes read so far.
+func (r *CountingReader) Count() int64 {
+	return r.n.Load()
+}
+
+func (r *CountingReader) add(n int) {
+	if n <= 0 {
+		return
+	}
+	total := r.n.Add(int64(n))
+	if r.fn != nil {
+		r.fn(total)
+	}
+}
+
+// CountingWriter is a Writer that counts the bytes written to the underlying Writer.
+// The count may be read concurrently with writes.
+type CountingWriter struct {
+	w Writer
+	n atomic.Int64
+}
+
+var _ ReaderFrom = (*CountingWriter)(nil)
+
+// NewCountingWriter returns a CountingWriter that writes to w.
+func NewCountingWriter(w Writer) *CountingWriter {
+	return &CountingWriter{w: w}
+}
+
+func (w *CountingWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	n, err := w.w.WriteContext(ctx, data)
+	w.add(n)
+	return n, err
+}
+
+// ReadFromContext implements ReaderFrom.
+// It uses the ReaderFrom of the underlying Writer if available.
+func (w *CountingWriter) ReadFromContext(ctx context.Context, r Reader) (int64, error) {
+	if rf, ok := w.w.(ReaderFrom); ok {
+		return rf.ReadFromContext(ctx, &countReader{r: r, add: w.add})
+	}
+	return copyBuffer(ctx, writerOnly{w}, r, nil)
+}
+
+// Count returns the number of bytes written so far.
+func (w *CountingWriter) Count() int64 {
+	return w.n.Load()
+}
+
+func (w *CountingWriter) add(n int) {
+	if n > 0 {
+		w.n.Add(int64(n))
+	}
+}
+
+// countReader calls add with the number of bytes read.
+type countReader struct {
+	r   Reader
+	add func(n int)
+}
+
+func (r *countReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	n, err := r.r.ReadContext(ctx, data)
+	r.add(n)
+	return n, err
+}
+
+// countWriter calls add with the number of bytes written.
+type countWriter struct {
+	w   Writer
+	add func(n int)
+}
+
+func (w *countWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	n, err := w.w.WriteContext(ctx, data)
+	w.add(n)
+	return n, err
+}
+
+// readerOnly hides the WriterTo method of a Reader.
+type readerOnly struct {
+	Reader
+}
+
+// writerOnly hides the ReaderFrom method of a Writer.
+type writerOnly struct {
+	Writer
+}
+
+// Progress describes the state of a copy in progress.
+type Progress struct {
+	// Bytes is the number of bytes copied so far.
+	Bytes int64
+
+	// Total is the expected number of bytes, or zero if it is unknown.
+	Total int64
+
+	// Elapsed is the time since the copy started.
+	Elapsed time.Duration
+
+	// Rate is the average number of bytes per second since the copy started.
+	Rate float64
+
+	// ETA is the estimated time to finish the copy.
+	// It is negative if Total is unknown.
+	ETA time.Duration
+}
+
+// ProgressOptions configures CopyWithProgress.
+type ProgressOptions struct {
+	// Total is the expected number of bytes to copy.
+	// Zero or negative means unknown.
+	Total int64
+
+	// Bytes reports the progress every time this many bytes are copied.
+	Bytes int64
+
+	// Interval reports the progress at most once per Interval.
+	Interval time.Duration
+}
+
+// CopyWithProgress is identical to Copy except that it calls fn
+// to report the progress of the copy.
+// fn is called when Bytes bytes have been copied or Interval has elapsed
+// since the last report, whichever comes first, and once more when the copy finishes.
+// Interval is measured by the Clock of ctx, so the progress is reported even if the copy stalls.
+// If neither Bytes nor Interval is set, fn is called after every transfer.
+// Calls to fn never overlap, and fn is not called after CopyWithProgress returns,
+// but the reports on Interval are made from another goroutine.
+//
+// CopyWithProgress keeps the WriterTo and ReaderFrom optimizations of Copy.
+func CopyWithProgress(ctx context.Context, dst Writer, src Reader, opts ProgressOptions, fn func(Progress)) (written int64, err error) {
+	clock := ClockFromContext(ctx)
+	r := NewCountingReader(src)
+	p := &progressReporter{
+		clock: clock,
+		opts:  opts,
+		fn:    fn,
+		count: r.Count,
+		start: clock.Now(),
+	}
+
+	p.mu.Lock()
+	p.schedule()
+	p.mu.Unlock()
+
+	r.fn = p.update
+	written, err = Copy(ctx, dst, r)
+	p.finish()
+	return written, err
+}
+
+type progressReporter struct {
+	clock Clock
+	opts  ProgressOptions
+	fn    func(Progress)
+	count func() int64
+	start time.Time
+
+	mu        sync.Mutex
+	lastBytes int64
+	timer     Timer
+	gen       int
+	done      bool
+}
+
+// update is called with the total after each transfer.
+func (p *progressReporter) update(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return
+	}
+	byBytes := p.opts.Bytes > 0 && n-p.lastBytes >= p.opts.Bytes
+	always := p.opts.Bytes <= 0 && p.opts.Interval <= 0
+	if byBytes || always {
+		p.report(n, p.clock.Now())
+		p.schedule()
+	}
+}
+
+// tick is called by the timer when Interval has elapsed since the last report.
+// gen tells the timers stopped too late from the current one.
+func (p *progressReporter) tick(gen int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done || gen != p.gen {
+		return
+	}
+	p.timer = nil
+	p.report(p.count(), p.clock.Now())
+	p.schedule()
+}
+
+// finish makes the last report and stops the timer.
+func (p *progressReporter) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = true
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.report(p.count(), p.clock.Now())
+}
+
+// schedule restarts the timer for the next report on Interval.
+// p.mu must be held.
+func (p *progressReporter) schedule() {
+	if p.opts.Interval <= 0 {
+		return
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.gen++
+	gen := p.gen
+	p.timer = p.clock.AfterFunc(p.opts.Interval, func() { p.tick(gen) })
+}
+
+func (p *progressReporter) report(n int64, now time.Time) {
+	p.lastBytes = n
+
+	elapsed := now.Sub(p.start)
+	progress := Progress{
+		Bytes:   n,
+		Elapsed: elapsed,
+		ETA:     -1,
+	}
+	if elapsed > 0 {
+		progress.Rate = float64(n) / elapsed.Seconds()
+	}
+	if p.opts.Total > 0 {
+		progress.Total = p.opts.Total
+		if remaining := p.opts.Total - n; remaining <= 0 {
+			progress.ETA = 0
+		} else if progress.Rate > 0 {
+			progress.ETA = time.Duration(float64(remaining) / progress.Rate * float64(time.Second))
+		}
+	}
+	p.fn(progress)
+}
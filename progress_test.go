@@ -0,0 +1,221 @@
+package ctxio_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+// writerToReader is a Reader that implements WriterTo.
+type writerToReader struct {
+	ctxio.Reader
+	called bool
+}
+
+func (r *writerToReader) WriteToContext(ctx context.Context, w ctxio.Writer) (int64, error) {
+	r.called = true
+	return ctxio.CopyBuffer(ctx, w, struct{ ctxio.Reader }{r.Reader}, make([]byte, 4))
+}
+
+// readerFromWriter is a Writer that implements ReaderFrom.
+type readerFromWriter struct {
+	ctxio.Writer
+	called bool
+}
+
+func (w *readerFromWriter) ReadFromContext(ctx context.Context, r ctxio.Reader) (int64, error) {
+	w.called = true
+	return ctxio.CopyBuffer(ctx, struct{ ctxio.Writer }{w.Writer}, r, make([]byte, 4))
+}
+
+func TestCountingReader(t *testing.T) {
+	r := ctxio.NewCountingReader(ctxio.NewReader(strings.NewReader("hello, world")))
+	data, err := ctxio.ReadAll(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", data)
+	}
+	if r.Count() != 12 {
+		t.Errorf("want 12, got %d", r.Count())
+	}
+}
+
+func TestCountingReader_WriterTo(t *testing.T) {
+	src := &writerToReader{Reader: ctxio.NewReader(strings.NewReader("hello, world"))}
+	r := ctxio.NewCountingReader(src)
+	var dst bytes.Buffer
+	_, err := ctxio.Copy(context.Background(), ctxio.NewWriter(&dst), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !src.called {
+		t.Error("WriteToContext of the underlying reader is not called")
+	}
+	if r.Count() != 12 {
+		t.Errorf("want 12, got %d", r.Count())
+	}
+	if dst.String() != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", dst.String())
+	}
+}
+
+func TestCountingWriter_ReaderFrom(t *testing.T) {
+	var buf bytes.Buffer
+	dst := &readerFromWriter{Writer: ctxio.NewWriter(&buf)}
+	w := ctxio.NewCountingWriter(dst)
+	_, err := ctxio.Copy(context.Background(), w, ctxio.NewReader(strings.NewReader("hello, world")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dst.called {
+		t.Error("ReadFromContext of the underlying writer is not called")
+	}
+	if w.Count() != 12 {
+		t.Errorf("want 12, got %d", w.Count())
+	}
+	if buf.String() != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", buf.String())
+	}
+}
+
+// tickingReader reads at most 10 bytes at once, and advances the clock on every read.
+type tickingReader struct {
+	r     ctxio.Reader
+	clock *ctxiotest.FakeClock
+	d     time.Duration
+}
+
+func (r *tickingReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	r.clock.Advance(r.d)
+	if len(data) > 10 {
+		data = data[:10]
+	}
+	return r.r.ReadContext(ctx, data)
+}
+
+func TestCopyWithProgress_Bytes(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := ctxio.WithClock(context.Background(), clock)
+	src := ctxio.NewReader(strings.NewReader(strings.Repeat("x", 100)))
+	r := &tickingReader{r: src, clock: clock, d: time.Second}
+
+	var reports []ctxio.Progress
+	opts := ctxio.ProgressOptions{Total: 100, Bytes: 40}
+	_, err := ctxio.CopyWithProgress(ctx, ctxio.Discard, r, opts, func(p ctxio.Progress) {
+		reports = append(reports, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 bytes per second: reports at 40, 80 and the final one.
+	want := []int64{40, 80, 100}
+	if len(reports) != len(want) {
+		t.Fatalf("want %d reports, got %d: %v", len(want), len(reports), reports)
+	}
+	for i, p := range reports {
+		if p.Bytes != want[i] {
+			t.Errorf("%d: want %d bytes, got %d", i, want[i], p.Bytes)
+		}
+	}
+	if reports[0].Rate != 10 {
+		t.Errorf("want rate 10, got %f", reports[0].Rate)
+	}
+	if reports[0].ETA != 6*time.Second {
+		t.Errorf("want ETA 6s, got %s", reports[0].ETA)
+	}
+	if last := reports[len(reports)-1]; last.ETA != 0 {
+		t.Errorf("want ETA 0, got %s", last.ETA)
+	}
+}
+
+func TestCopyWithProgress_Interval(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := ctxio.WithClock(context.Background(), clock)
+	src := ctxio.NewReader(strings.NewReader(strings.Repeat("x", 100)))
+	r := &tickingReader{r: src, clock: clock, d: time.Second}
+
+	var reports []ctxio.Progress
+	opts := ctxio.ProgressOptions{Interval: 3 * time.Second}
+	_, err := ctxio.CopyWithProgress(ctx, ctxio.Discard, r, opts, func(p ctxio.Progress) {
+		reports = append(reports, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 4 {
+		t.Fatalf("want 4 reports, got %d: %v", len(reports), reports)
+	}
+	for _, p := range reports {
+		if p.Total != 0 || p.ETA >= 0 {
+			t.Errorf("want unknown total, got %v", p)
+		}
+	}
+}
+
+// stallingReader returns data, and then blocks until release is closed.
+type stallingReader struct {
+	data    string
+	stalled chan struct{}
+	release chan struct{}
+}
+
+func (r *stallingReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	if r.data != "" {
+		n := copy(data, r.data)
+		r.data = r.data[n:]
+		return n, nil
+	}
+	close(r.stalled)
+	<-r.release
+	return 0, io.EOF
+}
+
+func TestCopyWithProgress_Stall(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := ctxio.WithClock(context.Background(), clock)
+	r := &stallingReader{data: "hello", stalled: make(chan struct{}), release: make(chan struct{})}
+
+	reports := make(chan ctxio.Progress, 10)
+	opts := ctxio.ProgressOptions{Interval: time.Second}
+	done := make(chan error, 1)
+	go func() {
+		_, err := ctxio.CopyWithProgress(ctx, ctxio.Discard, r, opts, func(p ctxio.Progress) {
+			reports <- p
+		})
+		done <- err
+	}()
+
+	// the progress is reported while no bytes move.
+	<-r.stalled
+	for i := 1; i <= 2; i++ {
+		clock.Advance(time.Second)
+		select {
+		case p := <-reports:
+			if p.Bytes != 5 || p.Elapsed != time.Duration(i)*time.Second {
+				t.Errorf("%d: want 5 bytes in %ds, got %v", i, i, p)
+			}
+		default:
+			t.Fatalf("%d: no report while the copy stalls", i)
+		}
+	}
+
+	close(r.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if p := <-reports; p.Bytes != 5 {
+		t.Errorf("want the final report of 5 bytes, got %v", p)
+	}
+	if clock.Timers() != 0 {
+		t.Errorf("want no timers, got %d", clock.Timers())
+	}
+}
@@ -0,0 +1,256 @@
+package ctxio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrTooLong is returned by ReadUntil, ReadDelim and ReadLine when the data exceeds the limit
+// before the delimiter is found.
+var ErrTooLong = errors.New("ctxio: token too long")
+
+// PushbackReader is a Reader with a buffer of data that is read before the underlying Reader.
+// ReadUntil, ReadDelim and ReadLine keep the data read ahead in the buffer,
+// so the following reads from the PushbackReader see all the data.
+type PushbackReader struct {
+	r          Reader
+	buf        []byte
+	start, end int
+
+	// err is the error of the underlying reader, returned after the buffered data.
+	err error
+}
+
+var _ WriterTo = (*PushbackReader)(nil)
+
+// NewPushbackReader returns a PushbackReader that reads from r.
+// If r is already a *PushbackReader, NewPushbackReader returns it.
+func NewPushbackReader(r Reader) *PushbackReader {
+	if pr, ok := r.(*PushbackReader); ok {
+		return pr
+	}
+	return &PushbackReader{r: r}
+}
+
+// Buffered returns the number of bytes that can be read without reading the underlying Reader.
+func (r *PushbackReader) Buffered() int {
+	return r.end - r.start
+}
+
+// Unread pushes data back, so it is read before the buffered data.
+func (r *PushbackReader) Unread(data []byte) {
+	if len(data) <= r.start {
+		r.start -= len(data)
+		copy(r.buf[r.start:], data)
+		return
+	}
+	buf := make([]byte, len(data)+r.end-r.start)
+	copy(buf, data)
+	copy(buf[len(data):], r.buf[r.start:r.end])
+	r.buf = buf
+	r.start, r.end = 0, len(buf)
+}
+
+func (r *PushbackReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if r.start < r.end {
+		n := copy(data, r.buf[r.start:r.end])
+		r.start += n
+		return n, nil
+	}
+	if err := r.readErr(); err != nil {
+		return 0, err
+	}
+	return r.r.ReadContext(ctx, data)
+}
+
+// WriteToContext implements WriterTo.
+// It writes the buffered data, and then copies from the underlying Reader.
+func (r *PushbackReader) WriteToContext(ctx context.Context, w Writer) (int64, error) {
+	var n int64
+	if r.start < r.end {
+		m, err := w.WriteContext(ctx, r.buf[r.start:r.end])
+		if m < 0 || m > r.end-r.start {
+			m = 0
+			if err == nil {
+				err = errInvalidWrite
+			}
+		}
+		r.start += m
+		n += int64(m)
+		if err == nil && r.start < r.end {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return n, rewrapError(OpCopy, SideDst, n, err)
+		}
+	}
+	if err := r.readErr(); err != nil {
+		if err == io.EOF {
+			return n, nil
+		}
+		return n, rewrapError(OpCopy, SideSrc, n, err)
+	}
+	m, err := Copy(ctx, w, r.r)
+	return n + m, err
+}
+
+func (r *PushbackReader) readErr() error {
+	err := r.err
+	r.err = nil
+	return err
+}
+
+// fill reads more data into the buffer. max limits the size of the buffer if positive.
+// It returns the error of the underlying reader if no data is read.
+func (r *PushbackReader) fill(ctx context.Context, max int) error {
+	if err := r.readErr(); err != nil {
+		return err
+	}
+
+	// make room for reading more data.
+	if r.start > 0 {
+		copy(r.buf, r.buf[r.start:r.end])
+		r.end -= r.start
+		r.start = 0
+	}
+	if r.end == len(r.buf) {
+		size := 2 * len(r.buf)
+		if size < 512 {
+			size = 512
+		}
+		if max > 0 && size > max && max > len(r.buf) {
+			size = max
+		}
+		buf := make([]byte, size)
+		copy(buf, r.buf[:r.end])
+		r.buf = buf
+	}
+
+	// give up after many reads without data or error, as bufio.Reader does.
+	for i := 0; i < 100; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := r.r.ReadContext(ctx, r.buf[r.end:])
+		r.end += n
+		if n > 0 {
+			r.err = err
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return io.ErrNoProgress
+}
+
+// ReadUntil reads from r until the first occurrence of delim,
+// and returns the data up to and including the delimiter.
+//
+// If r is a *PushbackReader, ReadUntil reads ahead, and the data read beyond the delimiter
+// stays buffered in r for the following reads.
+// Otherwise, ReadUntil reads r one byte at a time, so that nothing beyond the delimiter is consumed.
+//
+// If max is positive and no delimiter is found in the first max bytes,
+// ReadUntil returns an *OpError wrapping ErrTooLong.
+// If r reaches EOF before the delimiter, ReadUntil returns the rest of the data and io.EOF.
+// On the other errors, including the cancellation of ctx, no data is consumed from a *PushbackReader;
+// the data read so far stays buffered in r, so it can be read after the error.
+// The data read so far from the other readers is returned with the error instead.
+func ReadUntil(ctx context.Context, r Reader, delim byte, max int) ([]byte, error) {
+	if pr, ok := r.(*PushbackReader); ok {
+		return readUntil(ctx, pr, delim, max)
+	}
+	return readUntilByte(ctx, r, delim, max)
+}
+
+func readUntil(ctx context.Context, r *PushbackReader, delim byte, max int) ([]byte, error) {
+	scanned := 0
+	for {
+		if i := bytes.IndexByte(r.buf[r.start+scanned:r.end], delim); i >= 0 {
+			n := scanned + i + 1
+			if max > 0 && n > max {
+				return nil, wrapError(OpRead, "", 0, ErrTooLong)
+			}
+			data := make([]byte, n)
+			copy(data, r.buf[r.start:])
+			r.start += n
+			return data, nil
+		}
+		scanned = r.end - r.start
+		if max > 0 && scanned >= max {
+			return nil, wrapError(OpRead, "", 0, ErrTooLong)
+		}
+
+		if err := r.fill(ctx, max); err != nil {
+			if err == io.EOF && r.start < r.end {
+				data := make([]byte, r.end-r.start)
+				copy(data, r.buf[r.start:r.end])
+				r.start = r.end
+				return data, io.EOF
+			}
+			return nil, rewrapError(OpRead, "", 0, err)
+		}
+	}
+}
+
+// readUntilByte is readUntil for the readers that can't push back the data read ahead.
+func readUntilByte(ctx context.Context, r Reader, delim byte, max int) ([]byte, error) {
+	var data []byte
+	b := make([]byte, 1)
+
+	// give up after many reads without data or error, as bufio.Reader does.
+	for empty := 0; empty < 100; {
+		if max > 0 && len(data) >= max {
+			return data, wrapError(OpRead, "", int64(len(data)), ErrTooLong)
+		}
+		if err := ctx.Err(); err != nil {
+			return data, wrapError(OpRead, "", int64(len(data)), err)
+		}
+		n, err := r.ReadContext(ctx, b)
+		if n > 0 {
+			empty = 0
+			data = append(data, b[0])
+			if b[0] == delim {
+				return data, nil
+			}
+		} else if err == nil {
+			empty++
+		}
+		if err != nil {
+			if err == io.EOF && len(data) > 0 {
+				return data, io.EOF
+			}
+			return data, rewrapError(OpRead, "", int64(len(data)), err)
+		}
+	}
+	return data, wrapError(OpRead, "", int64(len(data)), io.ErrNoProgress)
+}
+
+// ReadDelim is like ReadUntil, but returns the data without the delimiter.
+// max limits the length of the data including the delimiter, if positive.
+func ReadDelim(ctx context.Context, r Reader, delim byte, max int) ([]byte, error) {
+	data, err := ReadUntil(ctx, r, delim, max)
+	if err == nil {
+		data = data[:len(data)-1]
+	}
+	return data, err
+}
+
+// ReadLine reads a line from r, and returns it without the end-of-line marker,
+// which is "\n" or "\r\n".
+// max limits the length of the line including the end-of-line marker, if positive.
+// The data read ahead and the errors are handled as ReadUntil does;
+// the last line without an end-of-line marker is returned with io.EOF.
+func ReadLine(ctx context.Context, r Reader, max int) ([]byte, error) {
+	line, err := ReadDelim(ctx, r, '\n', max)
+	if err == nil && len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, err
+}
@@ -0,0 +1,233 @@
+package ctxio_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+func newOneByteReader(s string) ctxio.Reader {
+	return ctxiotest.OneByteReader(ctxio.NewReader(strings.NewReader(s)))
+}
+
+func TestReadLine(t *testing.T) {
+	ctx := context.Background()
+	src := ctxiotest.HalfReader(ctxio.NewReader(strings.NewReader("GET / HTTP/1.1\r\nHost: example.com\n\nbody")))
+	r := ctxio.NewPushbackReader(src)
+
+	for _, want := range []string{"GET / HTTP/1.1", "Host: example.com", ""} {
+		line, err := ctxio.ReadLine(ctx, r, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(line) != want {
+			t.Errorf("want %q, got %q", want, line)
+		}
+	}
+
+	// the data read ahead is not lost.
+	rest, err := ctxio.ReadAll(ctx, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != "body" {
+		t.Errorf("want %q, got %q", "body", rest)
+	}
+}
+
+func TestReadUntil(t *testing.T) {
+	ctx := context.Background()
+	r := ctxio.NewPushbackReader(newOneByteReader("a;bb;ccc"))
+
+	for _, want := range []string{"a;", "bb;"} {
+		data, err := ctxio.ReadUntil(ctx, r, ';', 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("want %q, got %q", want, data)
+		}
+	}
+	data, err := ctxio.ReadUntil(ctx, r, ';', 0)
+	if err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+	if string(data) != "ccc" {
+		t.Errorf("want %q, got %q", "ccc", data)
+	}
+	if _, err := ctxio.ReadUntil(ctx, r, ';', 0); err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+}
+
+func TestReadDelim(t *testing.T) {
+	ctx := context.Background()
+	r := ctxio.NewPushbackReader(newOneByteReader("a;bb;ccc"))
+
+	for _, want := range []string{"a", "bb"} {
+		data, err := ctxio.ReadDelim(ctx, r, ';', 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("want %q, got %q", want, data)
+		}
+	}
+	data, err := ctxio.ReadDelim(ctx, r, ';', 0)
+	if err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+	if string(data) != "ccc" {
+		t.Errorf("want %q, got %q", "ccc", data)
+	}
+}
+
+func TestReadUntil_Reader(t *testing.T) {
+	ctx := context.Background()
+
+	// a Reader that is not a PushbackReader is not read beyond the delimiter.
+	r := ctxio.NewReader(strings.NewReader("hello\nworld\n"))
+	for _, want := range []string{"hello", "world"} {
+		line, err := ctxio.ReadLine(ctx, r, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(line) != want {
+			t.Errorf("want %q, got %q", want, line)
+		}
+	}
+	if _, err := ctxio.ReadLine(ctx, r, 0); err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+
+	// the data read before an error is returned with it.
+	r = ctxio.NewReader(io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errTest)))
+	data, err := ctxio.ReadUntil(ctx, r, ';', 0)
+	if string(data) != "partial" {
+		t.Errorf("want %q, got %q", "partial", data)
+	}
+	var opErr *ctxio.OpError
+	if !errors.As(err, &opErr) || opErr.Err != errTest || opErr.N != 7 {
+		t.Errorf("want *OpError of errTest after 7 bytes, got %#v", err)
+	}
+
+	// the data exceeding the limit is returned with ErrTooLong.
+	r = ctxio.NewReader(strings.NewReader("0123456789;"))
+	data, err = ctxio.ReadUntil(ctx, r, ';', 10)
+	if !errors.Is(err, ctxio.ErrTooLong) {
+		t.Errorf("want ErrTooLong, got %v", err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("want %q, got %q", "0123456789", data)
+	}
+}
+
+func TestReadUntil_TooLong(t *testing.T) {
+	ctx := context.Background()
+	for _, src := range []string{"0123456789;", "0123456789"} {
+		r := ctxio.NewPushbackReader(ctxio.NewReader(strings.NewReader(src)))
+
+		if _, err := ctxio.ReadUntil(ctx, r, ';', 10); !errors.Is(err, ctxio.ErrTooLong) {
+			t.Errorf("%q: want ErrTooLong, got %v", src, err)
+		}
+
+		// no data is consumed.
+		data, err := ctxio.ReadAll(ctx, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != src {
+			t.Errorf("want %q, got %q", src, data)
+		}
+	}
+
+	// the delimiter at the limit is accepted.
+	r := ctxio.NewPushbackReader(newOneByteReader("012345678;"))
+	data, err := ctxio.ReadUntil(ctx, r, ';', 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "012345678;" {
+		t.Errorf("want %q, got %q", "012345678;", data)
+	}
+}
+
+func TestReadUntil_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := &cancelingReader{r: newOneByteReader("hello, world\n"), after: 5, cancel: cancel}
+	r := ctxio.NewPushbackReader(src)
+	if _, err := ctxio.ReadLine(ctx, r, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+
+	// the line can be read again.
+	line, err := ctxio.ReadLine(context.Background(), r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(line) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", line)
+	}
+}
+
+// cancelingReader calls cancel after the given number of reads.
+type cancelingReader struct {
+	r      ctxio.Reader
+	after  int
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	r.after--
+	if r.after == 0 {
+		r.cancel()
+	}
+	return r.r.ReadContext(ctx, data)
+}
+
+func TestPushbackReader_Unread(t *testing.T) {
+	ctx := context.Background()
+	r := ctxio.NewPushbackReader(newOneByteReader("world\nrest"))
+	if _, err := ctxio.ReadLine(ctx, r, 0); err != nil {
+		t.Fatal(err)
+	}
+	r.Unread([]byte("hello, "))
+	r.Unread([]byte(">"))
+	if r.Buffered() != 8 {
+		t.Errorf("want 8, got %d", r.Buffered())
+	}
+
+	// Copy uses WriteToContext.
+	var buf strings.Builder
+	if _, err := ctxio.Copy(ctx, ctxio.NewWriter(&buf), r); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != ">hello, rest" {
+		t.Errorf("want %q, got %q", ">hello, rest", buf.String())
+	}
+}
+
+func TestPushbackReader_Error(t *testing.T) {
+	ctx := context.Background()
+	src := io.MultiReader(strings.NewReader("line\npartial"), iotest.ErrReader(errTest))
+	r := ctxio.NewPushbackReader(ctxio.NewReader(src))
+	if _, err := ctxio.ReadLine(ctx, r, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ctxio.ReadLine(ctx, r, 0); !errors.Is(err, errTest) {
+		t.Errorf("want errTest, got %v", err)
+	}
+	data, err := ctxio.ReadAll(ctx, r)
+	if string(data) != "partial" {
+		t.Errorf("want %q, got %q", "partial", data)
+	}
+	if !errors.Is(err, errTest) {
+		t.Errorf("want errTest, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package ctxio
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// Inf is the infinite rate limit; it allows all bytes through without waiting.
+const Inf = math.MaxFloat64
+
+var errExceedsBurst = errors.New("ctxio: wait exceeds the burst size of the limiter")
+
+var errNegativeCount = errors.New("ctxio: negative byte count")
+
+// A Limiter controls how many bytes per second are allowed to pass.
+// It implements a token bucket of size burst, refilled at rate bytes per second.
+//
+// A Limiter is safe for concurrent use, so one Limiter may be shared
+// by many streams to enforce a global limit.
+//
+// A Limiter measures time with the clock given to NewLimiterWithClock,
+// ignoring the clock carried by the contexts.
+type Limiter struct {
+	mu     sync.Mutex
+	clock  Clock
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a new Limiter that allows rate bytes per second
+// with bursts of at most burst bytes. The bucket starts full.
+// If burst is zero, no bytes are allowed unless rate is Inf.
+func NewLimiter(rate float64, burst int) *Limiter {
+	return NewLimiterWithClock(rate, burst, SystemClock)
+}
+
+// NewLimiterWithClock is like NewLimiter, but the returned Limiter measures time with c.
+func NewLimiterWithClock(rate float64, burst int, c Clock) *Limiter {
+	return &Limiter{
+		clock:  c,
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   c.Now(),
+	}
+}
+
+// Rate returns the maximum number of bytes per second.
+func (l *Limiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// SetRate changes the maximum number of bytes per second.
+// Callers already waiting are not affected.
+func (l *Limiter) SetRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.clock.Now())
+	l.rate = rate
+}
+
+// Burst returns the maximum burst size in bytes.
+func (l *Limiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
+
+// SetBurst changes the maximum burst size in bytes.
+func (l *Limiter) SetBurst(burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.clock.Now())
+	l.burst = burst
+	if l.tokens > float64(burst) {
+		l.tokens = float64(burst)
+	}
+}
+
+// WaitN blocks until n bytes are allowed to pass, or ctx is done.
+// It returns an error if n is negative or exceeds the burst size of the limiter.
+// If ctx is done before the bytes are allowed, the reserved tokens are
+// returned to the bucket and ctx.Err() is returned.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	return l.waitN(ctx, n, true)
+}
+
+// waitN is WaitN. If refund is false, the tokens are kept even if ctx is done,
+// because the bytes have already been transferred.
+func (l *Limiter) waitN(ctx context.Context, n int, refund bool) error {
+	if n < 0 {
+		return errNegativeCount
+	}
+	if refund {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	l.mu.Lock()
+	if l.rate == Inf || n == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	if n > l.burst {
+		l.mu.Unlock()
+		return errExceedsBurst
+	}
+	l.advance(l.clock.Now())
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	// wait for the bucket to be refilled.
+	// if the rate is zero, it is never refilled; wait for ctx.
+	var expired <-chan time.Time
+	if l.rate > 0 {
+		wait := time.Duration(math.Ceil(-l.tokens / l.rate * float64(time.Second)))
+		t := l.clock.NewTimer(wait)
+		defer t.Stop()
+		expired = t.C()
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-expired:
+		return nil
+	case <-ctx.Done():
+		if refund {
+			l.mu.Lock()
+			l.tokens += float64(n)
+			if l.tokens > float64(l.burst) {
+				l.tokens = float64(l.burst)
+			}
+			l.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// advance refills the bucket up to now.
+// l.mu must be held.
+func (l *Limiter) advance(now time.Time) {
+	if now.Before(l.last) {
+		return
+	}
+	if l.rate != Inf {
+		elapsed := now.Sub(l.last).Seconds()
+		l.tokens += elapsed * l.rate
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	l.last = now
+}
+
+// chunk returns the maximum number of bytes that can be transferred at once.
+// It returns zero if no bytes are allowed.
+func (l *Limiter) chunk(n int) int {
+	if l.Rate() == Inf {
+		return n
+	}
+	if burst := l.Burst(); n > burst {
+		n = burst
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+// waitForever blocks until ctx is done, for the limiters that allow no bytes.
+func waitForever(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// RateLimitReader returns a Reader that reads from r
+// at the rate allowed by l.
+func RateLimitReader(r Reader, l *Limiter) Reader {
+	return &rateLimitReader{r: r, l: l}
+}
+
+type rateLimitReader struct {
+	r Reader
+	l *Limiter
+}
+
+func (r *rateLimitReader) ReadContext(ctx context.Context, data []byte) (n int, err error) {
+	if len(data) == 0 {
+		return r.r.ReadContext(ctx, data)
+	}
+	m := r.l.chunk(len(data))
+	if m == 0 {
+		return 0, waitForever(ctx)
+	}
+	n, err = r.r.ReadContext(ctx, data[:m])
+	if n > 0 {
+		// the bytes are already read, so they are paid even if ctx is done.
+		if werr := r.l.waitN(ctx, n, false); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return
+}
+
+// RateLimitWriter returns a Writer that writes to w
+// at the rate allowed by l.
+func RateLimitWriter(w Writer, l *Limiter) Writer {
+	return &rateLimitWriter{w: w, l: l}
+}
+
+type rateLimitWriter struct {
+	w Writer
+	l *Limiter
+}
+
+func (w *rateLimitWriter) WriteContext(ctx context.Context, data []byte) (n int, err error) {
+	if len(data) == 0 {
+		return w.w.WriteContext(ctx, data)
+	}
+	for n < len(data) {
+		m := w.l.chunk(len(data) - n)
+		if m == 0 {
+			return n, waitForever(ctx)
+		}
+		if err := w.l.WaitN(ctx, m); err != nil {
+			return n, err
+		}
+		m, err = w.w.WriteContext(ctx, data[n:n+m])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
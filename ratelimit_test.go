@@ -0,0 +1,276 @@
+package ctxio_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+func TestLimiter_WaitN(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	l := ctxio.NewLimiterWithClock(100, 100, clock)
+	ctx := context.Background()
+
+	// the bucket starts full.
+	if err := l.WaitN(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	if clock.Timers() != 0 {
+		t.Fatal("want no timers")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l.WaitN(ctx, 50)
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(499 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("WaitN returned too early: %v", err)
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimiter_WaitNExceedsBurst(t *testing.T) {
+	l := ctxio.NewLimiter(100, 10)
+	if err := l.WaitN(context.Background(), 11); err == nil {
+		t.Error("want error, got nil")
+	}
+}
+
+func TestLimiter_WaitNNegative(t *testing.T) {
+	l := ctxio.NewLimiter(100, 10)
+	if err := l.WaitN(context.Background(), -100); err == nil {
+		t.Error("want error, got nil")
+	}
+	if err := l.WaitN(context.Background(), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.WaitN(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimiter_ZeroBurst(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	l := ctxio.NewLimiterWithClock(100, 0, clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	src := bytes.NewBufferString("hello")
+	r := ctxio.RateLimitReader(ctxio.NewReader(src), l)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.ReadContext(ctx, make([]byte, 5))
+		done <- err
+	}()
+	clock.Advance(time.Hour)
+	select {
+	case err := <-done:
+		t.Fatalf("ReadContext returned with zero burst: %v", err)
+	default:
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	if src.Len() != 5 {
+		t.Errorf("no bytes should be read, got %d left", src.Len())
+	}
+
+	// no limit is applied with Inf.
+	l.SetRate(ctxio.Inf)
+	n, err := r.ReadContext(context.Background(), make([]byte, 5))
+	if err != nil || n != 5 {
+		t.Errorf("want (5, nil), got (%d, %v)", n, err)
+	}
+}
+
+func TestLimiter_Canceled(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	l := ctxio.NewLimiterWithClock(100, 100, clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := l.WaitN(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l.WaitN(ctx, 100)
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+
+	// the canceled reservation must be returned to the bucket.
+	clock.Advance(time.Second)
+	if err := l.WaitN(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	}
+	if clock.Timers() != 0 {
+		t.Error("want no timers")
+	}
+}
+
+func TestLimiter_SetRate(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	l := ctxio.NewLimiterWithClock(100, 100, clock)
+	ctx := context.Background()
+	if err := l.WaitN(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	l.SetRate(ctxio.Inf)
+	if err := l.WaitN(ctx, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	l.SetRate(10)
+	done := make(chan error, 1)
+	go func() {
+		done <- l.WaitN(ctx, 10)
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimitReader(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := context.Background()
+	l := ctxio.NewLimiterWithClock(10, 10, clock)
+	r := ctxio.RateLimitReader(ctxio.NewReader(bytes.NewBufferString("hello, world")), l)
+
+	buf := make([]byte, 64)
+	n, err := r.ReadContext(ctx, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Errorf("want 10, got %d", n)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		n, err = r.ReadContext(ctx, buf[n:])
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(200 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:12]) != "hello, world" {
+		t.Errorf("want %q, got %q", "hello, world", buf[:12])
+	}
+}
+
+func TestRateLimitWriter(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := context.Background()
+	l := ctxio.NewLimiterWithClock(10, 10, clock)
+	dst := &bytes.Buffer{}
+	w := ctxio.RateLimitWriter(ctxio.NewWriter(dst), l)
+
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := w.WriteContext(ctx, []byte("hello, world"))
+		done <- result{n, err}
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(200 * time.Millisecond)
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.n != 12 {
+		t.Errorf("want 12, got %d", res.n)
+	}
+	if !bytes.Equal(dst.Bytes(), []byte("hello, world")) {
+		t.Errorf("want %q, got %q", "hello, world", dst.Bytes())
+	}
+}
+
+func TestRateLimitWriter_Canceled(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	l := ctxio.NewLimiterWithClock(10, 10, clock)
+	dst := &bytes.Buffer{}
+	w := ctxio.RateLimitWriter(ctxio.NewWriter(dst), l)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := w.WriteContext(ctx, []byte("hello, world"))
+		done <- result{n, err}
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	res := <-done
+	if !errors.Is(res.err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", res.err)
+	}
+	if res.n != 10 {
+		t.Errorf("want 10, got %d", res.n)
+	}
+}
+
+func TestRateLimitReader_Canceled(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	l := ctxio.NewLimiterWithClock(10, 10, clock)
+	r := ctxio.RateLimitReader(ctxio.NewReader(bytes.NewBufferString("hello, world")), l)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	buf := make([]byte, 64)
+	if _, err := r.ReadContext(ctx, buf); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.ReadContext(ctx, buf)
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+
+	// the bytes already read are paid, so the bucket is not refunded.
+	waited := make(chan error, 1)
+	go func() {
+		waited <- l.WaitN(context.Background(), 10)
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(1199 * time.Millisecond)
+	select {
+	case err := <-waited:
+		t.Fatalf("WaitN returned too early: %v", err)
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	if err := <-waited; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+package ctxio
+
+import (
+	"context"
+	"io"
+)
+
+// ReadAheadOptions configures CopyReadAhead.
+type ReadAheadOptions struct {
+	// Depth is the maximum number of buffers read ahead of the buffer being written.
+	// If it is zero, 4 is used.
+	Depth int
+
+	// BufferSize is the size of each buffer.
+	// If it is zero, 32KB is used.
+	BufferSize int
+}
+
+// CopyReadAhead is identical to Copy except that it reads from src into the next buffers
+// while the previous buffer is being written to dst,
+// so the latencies of src and dst overlap.
+// It doesn't use the WriterTo and ReaderFrom optimizations of Copy,
+// because they serialize reads and writes.
+//
+// written is the number of bytes written to dst. If an error occurs,
+// the bytes read from src but not written yet are discarded.
+// Cancellation of ctx stops both sides.
+//
+// If opts is nil, the zero ReadAheadOptions is used.
+func CopyReadAhead(ctx context.Context, dst Writer, src Reader, opts *ReadAheadOptions) (written int64, err error) {
+	depth, size := 4, 32*1024
+	if opts != nil {
+		if opts.Depth > 0 {
+			depth = opts.Depth
+		}
+		if opts.BufferSize > 0 {
+			size = opts.BufferSize
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type chunk struct {
+		buf []byte
+		n   int
+	}
+	free := make(chan []byte, depth+1)
+	for i := 0; i < depth+1; i++ {
+		free <- make([]byte, size)
+	}
+	full := make(chan chunk, depth+1)
+
+	// readErr is written by the reader goroutine before closing full.
+	var readErr error
+	go func() {
+		defer close(full)
+		for {
+			var buf []byte
+			select {
+			case buf = <-free:
+			case <-ctx.Done():
+				readErr = ctx.Err()
+				return
+			}
+			nr, er := src.ReadContext(ctx, buf)
+			if nr > 0 {
+				full <- chunk{buf, nr}
+			}
+			if er != nil {
+				readErr = er
+				return
+			}
+		}
+	}()
+
+	for c := range full {
+		if err != nil {
+			// drain the chunks read ahead, so the reader can finish.
+			continue
+		}
+		nw, ew := dst.WriteContext(ctx, c.buf[:c.n])
+		if nw < 0 || c.n < nw {
+			nw = 0
+			if ew == nil {
+				ew = errInvalidWrite
+			}
+		}
+		written += int64(nw)
+		if ew == nil && nw != c.n {
+			ew = io.ErrShortWrite
+		}
+		if ew != nil {
+			err = rewrapError(OpCopy, SideDst, written, ew)
+			cancel()
+			continue
+		}
+		free <- c.buf
+	}
+	if err != nil {
+		return written, err
+	}
+	if readErr != io.EOF {
+		err = rewrapError(OpCopy, SideSrc, written, readErr)
+	}
+	return written, err
+}
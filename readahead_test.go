@@ -0,0 +1,152 @@
+package ctxio_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+func TestCopyReadAhead(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	src := ctxio.NewReader(bytes.NewReader(data))
+	var dst bytes.Buffer
+	n, err := ctxio.CopyReadAhead(context.Background(), ctxio.NewWriter(&dst), struct{ ctxio.Reader }{src}, &ctxio.ReadAheadOptions{Depth: 2, BufferSize: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("want %d, got %d", len(data), n)
+	}
+	if !bytes.Equal(dst.Bytes(), data) {
+		t.Error("data mismatch")
+	}
+}
+
+// advancingWriter advances the clock after every write.
+type advancingWriter struct {
+	w     ctxio.Writer
+	clock *ctxiotest.FakeClock
+	d     time.Duration
+}
+
+func (w *advancingWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	n, err := w.w.WriteContext(ctx, data)
+	w.clock.Advance(w.d)
+	return n, err
+}
+
+func TestCopyReadAhead_Cancel(t *testing.T) {
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+	go pw.WriteContext(context.Background(), []byte("hello"))
+
+	// the deadline expires after the data is written.
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx, cancel := clock.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var buf bytes.Buffer
+	dst := &advancingWriter{w: ctxio.NewWriter(&buf), clock: clock, d: time.Second}
+	n, err := ctxio.CopyReadAhead(ctx, dst, pr, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+	if n != 5 || buf.String() != "hello" {
+		t.Errorf("want 5, %q, got %d, %q", "hello", n, buf.String())
+	}
+	var opErr *ctxio.OpError
+	if !errors.As(err, &opErr) || opErr.Side != ctxio.SideSrc {
+		t.Errorf("want *OpError of the src side, got %#v", err)
+	}
+}
+
+func TestCopyReadAhead_WriteCancel(t *testing.T) {
+	// the reader must stop even though it never blocks.
+	src := &infiniteReader{}
+	pr, pw := ctxio.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+
+	// the deadline expires after 10 bytes are read from the pipe.
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx, cancel := clock.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() {
+		buf := make([]byte, 10)
+		ctxio.ReadFull(context.Background(), pr, buf)
+		clock.Advance(time.Second)
+	}()
+
+	n, err := ctxio.CopyReadAhead(ctx, pw, src, &ctxio.ReadAheadOptions{BufferSize: 4})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want context.DeadlineExceeded, got %v", err)
+	}
+	if n != 10 {
+		t.Errorf("want 10, got %d", n)
+	}
+}
+
+type infiniteReader struct{}
+
+func (infiniteReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	for i := range data {
+		data[i] = 'x'
+	}
+	return len(data), nil
+}
+
+// latencyReader returns size bytes per read after the latency.
+type latencyReader struct {
+	n       int64
+	latency time.Duration
+}
+
+func (r *latencyReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.latency)
+	if int64(len(data)) > r.n {
+		data = data[:r.n]
+	}
+	r.n -= int64(len(data))
+	return len(data), nil
+}
+
+// latencyWriter accepts every write after the latency.
+type latencyWriter struct {
+	latency time.Duration
+}
+
+func (w latencyWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
+	time.Sleep(w.latency)
+	return len(data), nil
+}
+
+const benchmarkLatency = 100 * time.Microsecond
+
+func BenchmarkCopy_Latency(b *testing.B) {
+	b.SetBytes(1 << 20)
+	for i := 0; i < b.N; i++ {
+		src := &latencyReader{n: 1 << 20, latency: benchmarkLatency}
+		if _, err := ctxio.Copy(context.Background(), latencyWriter{benchmarkLatency}, src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCopyReadAhead_Latency(b *testing.B) {
+	b.SetBytes(1 << 20)
+	for i := 0; i < b.N; i++ {
+		src := &latencyReader{n: 1 << 20, latency: benchmarkLatency}
+		if _, err := ctxio.CopyReadAhead(context.Background(), latencyWriter{benchmarkLatency}, src, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
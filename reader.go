@@ -4,14 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/fs"
+	"os"
 	"strings"
 	"sync"
 	"time"
 )
 
-type readDeadlineSetter interface {
+// ReadDeadlineSetter is the interface that wraps the SetReadDeadline method.
+// It is implemented by net.Conn, *os.File and *http.ResponseController.
+type ReadDeadlineSetter interface {
 	SetReadDeadline(t time.Time) error
 }
 
@@ -31,28 +35,38 @@ func NewReader(reader io.Reader) ReadCloser {
 		return NopCloser(r)
 	}
 
-	if setter, ok := reader.(readDeadlineSetter); ok {
-		if err := setter.SetReadDeadline(time.Time{}); err == nil {
-			return newWatchReader(reader, setter)
-		}
+	if setter, ok := reader.(ReadDeadlineSetter); ok {
+		return NewDeadlineReader(reader, setter)
+	}
+	return newGoReader(reader)
+}
+
+// NewDeadlineReader returns a ReadCloser that reads from reader,
+// and cancels blocked reads by setting the read deadline through setter.
+// If setter doesn't support deadlines, reads are done in another goroutine instead.
+// Close stops watching the context; it doesn't close reader.
+func NewDeadlineReader(reader io.Reader, setter ReadDeadlineSetter) ReadCloser {
+	if err := setter.SetReadDeadline(time.Time{}); err == nil {
+		return newWatchReader(reader, setter)
 	}
 	return newGoReader(reader)
 }
 
 type watchReader struct {
 	r        io.Reader
-	setter   readDeadlineSetter
+	setter   ReadDeadlineSetter
 	watcher  chan<- context.Context
 	finished chan<- struct{}
 
 	closed    chan struct{}
 	closeOnce sync.Once
 
-	mu  sync.Mutex
-	err error
+	mu       sync.Mutex
+	err      error
+	deadline time.Time
 }
 
-func newWatchReader(reader io.Reader, setter readDeadlineSetter) ReadCloser {
+func newWatchReader(reader io.Reader, setter ReadDeadlineSetter) ReadCloser {
 	watcher := make(chan context.Context, 1)
 	finished := make(chan struct{})
 	closed := make(chan struct{})
@@ -92,8 +106,17 @@ func newWatchReader(reader io.Reader, setter readDeadlineSetter) ReadCloser {
 }
 
 func (r *watchReader) ReadContext(ctx context.Context, data []byte) (n int, err error) {
-	if r.watchCancel(ctx); err != nil {
-		return 0, err
+	return r.readContext(ctx, data, time.Time{})
+}
+
+// readContext is same as ReadContext, but also sets the read deadline of the underlying reader.
+// Expiry of the deadline is reported as ErrIdleTimeout.
+func (r *watchReader) readContext(ctx context.Context, data []byte, deadline time.Time) (n int, err error) {
+	// set the deadline before starting to watch ctx,
+	// so that it doesn't overwrite the deadline set by the cancellation.
+	r.setDeadline(deadline)
+	if err := r.watchCancel(ctx); err != nil {
+		return 0, wrapError(OpRead, "", 0, err)
 	}
 
 	n, err = r.r.Read(data)
@@ -101,10 +124,12 @@ func (r *watchReader) ReadContext(ctx context.Context, data []byte) (n int, err
 		canceled := r.canceled()
 		if canceled != nil {
 			err = canceled
+		} else if !deadline.IsZero() && errors.Is(err, os.ErrDeadlineExceeded) {
+			err = ErrIdleTimeout
 		}
 	}
 	r.finish()
-	return
+	return n, wrapError(OpRead, "", int64(n), err)
 }
 
 func (r *watchReader) Close() error {
@@ -141,11 +166,24 @@ func (r *watchReader) finish() {
 func (r *watchReader) cancel(err error) {
 	r.mu.Lock()
 	r.err = err
+	r.deadline = aLongTimeAgo
 	r.mu.Unlock()
 
 	r.setter.SetReadDeadline(aLongTimeAgo)
 }
 
+// setDeadline sets the deadline of the underlying reader if it is changed.
+func (r *watchReader) setDeadline(t time.Time) {
+	r.mu.Lock()
+	changed := !r.deadline.Equal(t)
+	r.deadline = t
+	r.mu.Unlock()
+
+	if changed {
+		r.setter.SetReadDeadline(t)
+	}
+}
+
 func (r *watchReader) canceled() error {
 	r.mu.Lock()
 	err := r.err
@@ -160,6 +198,7 @@ type goReader struct {
 
 	mu    sync.Mutex
 	buf   []byte
+	pool  BufferPool // the pool of buf
 	start int
 	end   int
 	req   chan readRequest
@@ -167,13 +206,15 @@ type goReader struct {
 }
 
 type readRequest struct {
-	n int
+	buf  []byte
+	pool BufferPool
 }
 
 type readResult struct {
-	buf []byte
-	n   int
-	err error
+	buf  []byte
+	pool BufferPool
+	n    int
+	err  error
 }
 
 func newGoReader(reader io.Reader) ReadCloser {
@@ -204,25 +245,32 @@ func (r *goReader) ReadContext(ctx context.Context, data []byte) (n int, err err
 		copy(data, r.buf[r.start:end])
 		n = end - r.start
 		r.start = end
+		r.release()
 		return
 	}
 
-	// send a read request
+	// send a read request.
+	// the buffer is owned by the loop until the result is received.
 	var res readResult
+	pool := BufferPoolFromContext(ctx)
+	req := readRequest{buf: pool.Get(len(data)), pool: pool}
 	select {
-	case r.req <- readRequest{n: len(data)}:
+	case r.req <- req:
 		select {
 		case res = <-r.res:
 		case <-r.closed:
-			return 0, fs.ErrClosed
+			return 0, wrapError(OpRead, "", 0, fs.ErrClosed)
 		case <-ctx.Done():
-			return 0, ctx.Err()
+			return 0, wrapError(OpRead, "", 0, ctx.Err())
 		}
 	case res = <-r.res:
+		pool.Put(req.buf)
 	case <-r.closed:
-		return 0, fs.ErrClosed
+		pool.Put(req.buf)
+		return 0, wrapError(OpRead, "", 0, fs.ErrClosed)
 	case <-ctx.Done():
-		return 0, ctx.Err()
+		pool.Put(req.buf)
+		return 0, wrapError(OpRead, "", 0, ctx.Err())
 	}
 
 	end := len(data)
@@ -233,7 +281,20 @@ func (r *goReader) ReadContext(ctx context.Context, data []byte) (n int, err err
 	r.start = end
 	r.end = res.n
 	r.buf = res.buf
-	return end, res.err
+	r.pool = res.pool
+	r.release()
+	return end, wrapError(OpRead, "", int64(end), res.err)
+}
+
+// release returns the buffer to the pool if all buffered data is read.
+// r.mu must be held.
+func (r *goReader) release() {
+	if r.start == r.end && r.buf != nil {
+		r.pool.Put(r.buf)
+		r.buf = nil
+		r.pool = nil
+		r.start, r.end = 0, 0
+	}
 }
 
 func (r *goReader) Close() error {
@@ -244,8 +305,6 @@ func (r *goReader) Close() error {
 }
 
 func (r *goReader) loop() {
-	buf1 := []byte{}
-	buf2 := []byte{}
 	for {
 		// receive a read request
 		var req readRequest
@@ -256,24 +315,20 @@ func (r *goReader) loop() {
 		}
 
 		// handle the request
-		if cap(buf1) < req.n {
-			buf1 = make([]byte, req.n)
-		}
-		n, err := r.r.Read(buf1[:req.n])
+		n, err := r.r.Read(req.buf)
 
 		// send a response
 		res := readResult{
-			buf: buf1[:req.n],
-			n:   n,
-			err: err,
+			buf:  req.buf,
+			pool: req.pool,
+			n:    n,
+			err:  err,
 		}
 		select {
 		case r.res <- res:
 		case <-r.closed:
 			return
 		}
-
-		buf1, buf2 = buf2, buf1
 	}
 }
 
@@ -282,7 +337,8 @@ type nopReader struct {
 }
 
 func (r *nopReader) ReadContext(ctx context.Context, data []byte) (int, error) {
-	return r.Read(data)
+	n, err := r.Read(data)
+	return n, wrapError(OpRead, "", int64(n), err)
 }
 
 func (r *nopReader) Close() error {
@@ -1,4 +1,4 @@
-package ctxio
+package ctxio_test
 
 import (
 	"context"
@@ -7,8 +7,33 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
 )
 
+var epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// startedReader closes started when the first Read is called,
+// so that tests can wait for the read to block.
+type startedReader struct {
+	r       io.Reader
+	started chan struct{}
+}
+
+func newStartedReader(r io.Reader) *startedReader {
+	return &startedReader{r: r, started: make(chan struct{})}
+}
+
+func (r *startedReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.started:
+	default:
+		close(r.started)
+	}
+	return r.r.Read(p)
+}
+
 func TestWatchReader(t *testing.T) {
 	r, w, err := os.Pipe()
 	if err != nil {
@@ -22,10 +47,12 @@ func TestWatchReader(t *testing.T) {
 		}
 	}()
 
-	rr := NewReader(r)
+	rr := ctxio.NewReader(r)
 	defer rr.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	// the clock never moves, so the read is never canceled.
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx, cancel := clock.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	buf := make([]byte, 128)
 	n, err := rr.ReadContext(ctx, buf)
@@ -45,11 +72,17 @@ func TestWatchReader_Timeout(t *testing.T) {
 	defer r.Close()
 	defer w.Close()
 
-	rr := NewReader(r)
+	sr := newStartedReader(r)
+	rr := ctxio.NewDeadlineReader(sr, r)
 	defer rr.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx, cancel := clock.WithTimeout(context.Background(), time.Second)
 	defer cancel()
+	go func() {
+		<-sr.started
+		clock.Advance(time.Second)
+	}()
 	buf := make([]byte, 128)
 	n, err := rr.ReadContext(ctx, buf)
 	if !errors.Is(err, context.DeadlineExceeded) {
@@ -70,10 +103,12 @@ func TestGoReader(t *testing.T) {
 	}()
 	defer r.Close()
 
-	rr := NewReader(r)
+	rr := ctxio.NewReader(r)
 	defer rr.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	// the clock never moves, so the read is never canceled.
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx, cancel := clock.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	buf := make([]byte, 128)
 	n, err := rr.ReadContext(ctx, buf)
@@ -90,11 +125,17 @@ func TestGoReader_Timeout(t *testing.T) {
 	defer r.Close()
 	defer w.Close()
 
-	rr := NewReader(r)
+	sr := newStartedReader(r)
+	rr := ctxio.NewReader(sr)
 	defer rr.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx, cancel := clock.WithTimeout(context.Background(), time.Second)
 	defer cancel()
+	go func() {
+		<-sr.started
+		clock.Advance(time.Second)
+	}()
 	buf := make([]byte, 128)
 	n, err := rr.ReadContext(ctx, buf)
 	if !errors.Is(err, context.DeadlineExceeded) {
@@ -0,0 +1,183 @@
+package ctxio
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"math/rand"
+	"time"
+)
+
+// OpenFunc opens a source positioned at offset,
+// such as an HTTP request with a Range header.
+type OpenFunc func(ctx context.Context, offset int64) (ReadCloser, error)
+
+// RetryPolicy configures the retries of the reader returned by NewResumableReader.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of consecutive attempts that fail
+	// without reading any bytes, including the first one.
+	// A successful read resets the count.
+	// If it is zero, 5 is used.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry.
+	// It doubles on each retry up to MaxBackoff.
+	// The actual wait is chosen randomly between the half of the backoff and the backoff.
+	// If it is zero, 100ms is used.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum wait before a retry.
+	// If it is zero, 10s is used.
+	MaxBackoff time.Duration
+
+	// Retryable reports whether err is transient and the source should be reopened.
+	// Errors are never retried after the context of the read is done.
+	// If it is nil, all errors are retried.
+	Retryable func(err error) bool
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts <= 0 {
+		return 5
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the wait before the retry after the attempt-th failure.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d, max := 100*time.Millisecond, 10*time.Second
+	if p != nil && p.InitialBackoff > 0 {
+		d = p.InitialBackoff
+	}
+	if p != nil && p.MaxBackoff > 0 {
+		max = p.MaxBackoff
+	}
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p == nil || p.Retryable == nil {
+		return true
+	}
+	return p.Retryable(err)
+}
+
+// ResumableReader is a Reader that reopens its source at the current offset
+// when a read fails with a transient error, and continues transparently.
+// It is returned by NewResumableReader.
+//
+// A ResumableReader is not safe for concurrent use.
+type ResumableReader struct {
+	open     OpenFunc
+	policy   *RetryPolicy
+	rc       ReadCloser
+	offset   int64
+	failures int
+	err      error
+}
+
+// NewResumableReader returns a ResumableReader that reads from the source opened by open,
+// starting at offset. The source is opened by the first read.
+//
+// When open or a read of the source fails with an error that policy considers retryable,
+// the source is closed, and open is called again at the offset
+// following the last byte read, after a backoff.
+// Other errors, and the last error after policy.MaxAttempts failures in a row,
+// are permanent, and returned by all the following reads.
+// The backoff waits on the context of the read, using the clock carried by it.
+// If policy is nil, the zero RetryPolicy is used.
+func NewResumableReader(open OpenFunc, offset int64, policy *RetryPolicy) *ResumableReader {
+	return &ResumableReader{
+		open:   open,
+		policy: policy,
+		offset: offset,
+	}
+}
+
+// Offset returns the offset of the next byte to read.
+func (r *ResumableReader) Offset() int64 {
+	return r.offset
+}
+
+func (r *ResumableReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	for {
+		var n int
+		var err error
+		if r.rc == nil {
+			var rc ReadCloser
+			rc, err = r.open(ctx, r.offset)
+			if err == nil {
+				r.rc = rc
+			}
+		}
+		if err == nil {
+			n, err = r.rc.ReadContext(ctx, data)
+			r.offset += int64(n)
+			if n > 0 {
+				r.failures = 0
+			}
+			if err == nil || err == io.EOF {
+				return n, err
+			}
+			r.rc.Close()
+			r.rc = nil
+		}
+
+		if ctx.Err() != nil {
+			return n, wrapError(OpRead, "", int64(n), ctx.Err())
+		}
+		if !r.policy.retryable(err) {
+			// the error is permanent; report it after the bytes read.
+			r.err = wrapError(OpRead, "", 0, err)
+			if n > 0 {
+				return n, nil
+			}
+			return 0, r.err
+		}
+		if n > 0 {
+			// report the bytes read, and reopen in the next read.
+			return n, nil
+		}
+		r.failures++
+		if r.failures >= r.policy.maxAttempts() {
+			r.err = wrapError(OpRead, "", 0, err)
+			return 0, r.err
+		}
+
+		timer := ClockFromContext(ctx).NewTimer(r.policy.backoff(r.failures))
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, wrapError(OpRead, "", 0, ctx.Err())
+		}
+	}
+}
+
+// Close closes the source if it is open.
+// The reads after Close fail.
+func (r *ResumableReader) Close() error {
+	if r.err == nil {
+		r.err = wrapError(OpRead, "", 0, fs.ErrClosed)
+	}
+	if r.rc == nil {
+		return nil
+	}
+	rc := r.rc
+	r.rc = nil
+	return rc.Close()
+}
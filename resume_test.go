@@ -0,0 +1,261 @@
+package ctxio_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
+)
+
+var errFlaky = errors.New("flaky")
+
+// flakySource opens readers of content that fail after failAfter bytes.
+type flakySource struct {
+	content   []byte
+	failAfter int
+	openErrs  []error // errors returned by the next opens
+	offsets   []int64
+	closed    int
+}
+
+func (s *flakySource) open(ctx context.Context, offset int64) (ctxio.ReadCloser, error) {
+	s.offsets = append(s.offsets, offset)
+	if len(s.openErrs) > 0 {
+		err := s.openErrs[0]
+		s.openErrs = s.openErrs[1:]
+		return nil, err
+	}
+	end := offset + int64(s.failAfter)
+	if end > int64(len(s.content)) {
+		end = int64(len(s.content))
+	}
+	return &flakyReader{s: s, data: s.content[offset:end], eof: end == int64(len(s.content))}, nil
+}
+
+type flakyReader struct {
+	s    *flakySource
+	data []byte
+	eof  bool
+}
+
+func (r *flakyReader) ReadContext(ctx context.Context, data []byte) (int, error) {
+	n := copy(data, r.data)
+	r.data = r.data[n:]
+	if len(r.data) == 0 {
+		if r.eof {
+			return n, io.EOF
+		}
+		return n, errFlaky
+	}
+	return n, nil
+}
+
+func (r *flakyReader) Close() error {
+	r.s.closed++
+	return nil
+}
+
+func TestResumableReader(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	s := &flakySource{content: content, failAfter: 300}
+	r := ctxio.NewResumableReader(s.open, 0, &ctxio.RetryPolicy{InitialBackoff: time.Nanosecond})
+	defer r.Close()
+
+	got, err := ctxio.ReadAll(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("content mismatch")
+	}
+	if r.Offset() != int64(len(content)) {
+		t.Errorf("want offset %d, got %d", len(content), r.Offset())
+	}
+	want := []int64{0, 300, 600, 900}
+	if len(s.offsets) != len(want) {
+		t.Fatalf("want opens at %v, got %v", want, s.offsets)
+	}
+	for i := range want {
+		if s.offsets[i] != want[i] {
+			t.Errorf("want opens at %v, got %v", want, s.offsets)
+			break
+		}
+	}
+	if s.closed != 3 {
+		t.Errorf("want 3 closes, got %d", s.closed)
+	}
+}
+
+func TestResumableReader_Offset(t *testing.T) {
+	content := []byte("0123456789")
+	s := &flakySource{content: content, failAfter: 10}
+	r := ctxio.NewResumableReader(s.open, 4, nil)
+	defer r.Close()
+
+	got, err := ctxio.ReadAll(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "456789" {
+		t.Errorf("want %q, got %q", "456789", got)
+	}
+}
+
+func TestResumableReader_MaxAttempts(t *testing.T) {
+	s := &flakySource{
+		content:  []byte("0123456789"),
+		openErrs: []error{errFlaky, errFlaky, errFlaky},
+	}
+	r := ctxio.NewResumableReader(s.open, 0, &ctxio.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Nanosecond})
+	defer r.Close()
+
+	if _, err := r.ReadContext(context.Background(), make([]byte, 10)); !errors.Is(err, errFlaky) {
+		t.Errorf("want errFlaky, got %v", err)
+	}
+	if len(s.offsets) != 3 {
+		t.Errorf("want 3 attempts, got %d", len(s.offsets))
+	}
+
+	// the error is permanent, and the source is not opened again.
+	if _, err := r.ReadContext(context.Background(), make([]byte, 10)); !errors.Is(err, errFlaky) {
+		t.Errorf("want errFlaky, got %v", err)
+	}
+	if len(s.offsets) != 3 {
+		t.Errorf("want 3 attempts, got %d", len(s.offsets))
+	}
+}
+
+func TestResumableReader_Retryable(t *testing.T) {
+	errPermanent := errors.New("permanent")
+	s := &flakySource{
+		content:  []byte("0123456789"),
+		openErrs: []error{errFlaky, errPermanent},
+	}
+	r := ctxio.NewResumableReader(s.open, 0, &ctxio.RetryPolicy{
+		InitialBackoff: time.Nanosecond,
+		Retryable: func(err error) bool {
+			return err == errFlaky
+		},
+	})
+	defer r.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.ReadContext(context.Background(), make([]byte, 10)); !errors.Is(err, errPermanent) {
+			t.Errorf("want errPermanent, got %v", err)
+		}
+	}
+	if len(s.offsets) != 2 {
+		t.Errorf("want 2 attempts, got %d", len(s.offsets))
+	}
+}
+
+func TestResumableReader_Backoff(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx := ctxio.WithClock(context.Background(), clock)
+	s := &flakySource{
+		content:   []byte("0123456789"),
+		openErrs:  []error{errFlaky, errFlaky},
+		failAfter: 10,
+	}
+	r := ctxio.NewResumableReader(s.open, 0, &ctxio.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 1500 * time.Millisecond})
+	defer r.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		n, err := r.ReadContext(ctx, make([]byte, 10))
+		if n != 10 {
+			err = fmt.Errorf("want 10 bytes, got %d (%v)", n, err)
+		} else if err == io.EOF {
+			err = nil
+		}
+		done <- err
+	}()
+
+	// the first backoff is between 0.5s and 1s.
+	clock.BlockUntil(1)
+	clock.Advance(500*time.Millisecond - 1)
+	if clock.Timers() != 1 {
+		t.Fatal("the backoff is too short")
+	}
+	clock.Advance(500*time.Millisecond + 1)
+
+	// the second backoff is between 0.75s and 1.5s.
+	clock.BlockUntil(1)
+	clock.Advance(750*time.Millisecond - 1)
+	if clock.Timers() != 1 {
+		t.Fatal("the backoff is too short")
+	}
+	clock.Advance(750*time.Millisecond + 1)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResumableReader_Cancel(t *testing.T) {
+	clock := ctxiotest.NewFakeClock(epoch)
+	ctx, cancel := context.WithCancel(ctxio.WithClock(context.Background(), clock))
+	s := &flakySource{
+		content:  []byte("0123456789"),
+		openErrs: []error{errFlaky},
+	}
+	r := ctxio.NewResumableReader(s.open, 0, nil)
+	defer r.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.ReadContext(ctx, make([]byte, 10))
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestResumableReader_Close(t *testing.T) {
+	s := &flakySource{content: []byte("0123456789"), failAfter: 10}
+	r := ctxio.NewResumableReader(s.open, 0, nil)
+	if _, err := r.ReadContext(context.Background(), make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s.closed != 1 {
+		t.Errorf("want the source closed, got %d closes", s.closed)
+	}
+	if _, err := r.ReadContext(context.Background(), make([]byte, 5)); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("want fs.ErrClosed, got %v", err)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &ctxio.RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for _, tt := range []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{100, time.Second},
+	} {
+		for i := 0; i < 100; i++ {
+			d := ctxio.RetryBackoff(p, tt.attempt)
+			if d < tt.max/2 || d > tt.max {
+				t.Errorf("attempt %d: want between %v and %v, got %v", tt.attempt, tt.max/2, tt.max, d)
+				break
+			}
+		}
+	}
+}
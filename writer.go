@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -12,7 +14,9 @@ import (
 
 const writeBufferSize = 32 * 1024
 
-type writeDeadlineSetter interface {
+// WriteDeadlineSetter is the interface that wraps the SetWriteDeadline method.
+// It is implemented by net.Conn, *os.File and *http.ResponseController.
+type WriteDeadlineSetter interface {
 	SetWriteDeadline(t time.Time) error
 }
 
@@ -40,28 +44,38 @@ func NewWriter(writer io.Writer) WriteCloser {
 	case Writer:
 		return writeCloser{w}
 	}
-	if setter, ok := writer.(writeDeadlineSetter); ok {
-		if err := setter.SetWriteDeadline(time.Time{}); err == nil {
-			return newWatchWriter(writer, setter)
-		}
+	if setter, ok := writer.(WriteDeadlineSetter); ok {
+		return NewDeadlineWriter(writer, setter)
+	}
+	return newGoWriter(writer)
+}
+
+// NewDeadlineWriter returns a WriteCloser that writes to writer,
+// and cancels blocked writes by setting the write deadline through setter.
+// If setter doesn't support deadlines, writes are done in another goroutine instead.
+// Close stops watching the context; it doesn't close writer.
+func NewDeadlineWriter(writer io.Writer, setter WriteDeadlineSetter) WriteCloser {
+	if err := setter.SetWriteDeadline(time.Time{}); err == nil {
+		return newWatchWriter(writer, setter)
 	}
 	return newGoWriter(writer)
 }
 
 type watchWriter struct {
 	w        io.Writer
-	setter   writeDeadlineSetter
+	setter   WriteDeadlineSetter
 	watcher  chan<- context.Context
 	finished chan<- struct{}
 
 	closed    chan struct{}
 	closeOnce sync.Once
 
-	mu  sync.Mutex
-	err error
+	mu       sync.Mutex
+	err      error
+	deadline time.Time
 }
 
-func newWatchWriter(writer io.Writer, setter writeDeadlineSetter) WriteCloser {
+func newWatchWriter(writer io.Writer, setter WriteDeadlineSetter) WriteCloser {
 	watcher := make(chan context.Context, 1)
 	finished := make(chan struct{})
 	closed := make(chan struct{})
@@ -101,8 +115,17 @@ func newWatchWriter(writer io.Writer, setter writeDeadlineSetter) WriteCloser {
 }
 
 func (w *watchWriter) WriteContext(ctx context.Context, data []byte) (n int, err error) {
-	if w.watchCancel(ctx); err != nil {
-		return 0, err
+	return w.writeContext(ctx, data, time.Time{})
+}
+
+// writeContext is same as WriteContext, but also sets the write deadline of the underlying writer.
+// Expiry of the deadline is reported as ErrIdleTimeout.
+func (w *watchWriter) writeContext(ctx context.Context, data []byte, deadline time.Time) (n int, err error) {
+	// set the deadline before starting to watch ctx,
+	// so that it doesn't overwrite the deadline set by the cancellation.
+	w.setDeadline(deadline)
+	if err := w.watchCancel(ctx); err != nil {
+		return 0, wrapError(OpWrite, "", 0, err)
 	}
 
 	n, err = w.w.Write(data)
@@ -110,10 +133,12 @@ func (w *watchWriter) WriteContext(ctx context.Context, data []byte) (n int, err
 		canceled := w.canceled()
 		if canceled != nil {
 			err = canceled
+		} else if !deadline.IsZero() && errors.Is(err, os.ErrDeadlineExceeded) {
+			err = ErrIdleTimeout
 		}
 	}
 	w.finish()
-	return
+	return n, wrapError(OpWrite, "", int64(n), err)
 }
 
 func (w *watchWriter) Close() error {
@@ -150,11 +175,24 @@ func (w *watchWriter) finish() {
 func (w *watchWriter) cancel(err error) {
 	w.mu.Lock()
 	w.err = err
+	w.deadline = aLongTimeAgo
 	w.mu.Unlock()
 
 	w.setter.SetWriteDeadline(aLongTimeAgo)
 }
 
+// setDeadline sets the deadline of the underlying writer if it is changed.
+func (w *watchWriter) setDeadline(t time.Time) {
+	w.mu.Lock()
+	changed := !w.deadline.Equal(t)
+	w.deadline = t
+	w.mu.Unlock()
+
+	if changed {
+		w.setter.SetWriteDeadline(t)
+	}
+}
+
 func (w *watchWriter) canceled() error {
 	w.mu.Lock()
 	err := w.err
@@ -164,6 +202,7 @@ func (w *watchWriter) canceled() error {
 
 type writeRequest struct {
 	data []byte
+	pool BufferPool // the pool of data
 	ch   chan writeResponse
 }
 
@@ -177,18 +216,14 @@ type goWriter struct {
 	closed    chan struct{}
 	closeOnce sync.Once
 
-	mu   sync.Mutex
-	buf1 []byte
-	buf2 []byte
-	ch   chan writeRequest
+	mu sync.Mutex
+	ch chan writeRequest
 }
 
 func newGoWriter(writer io.Writer) WriteCloser {
 	w := &goWriter{
 		w:      writer,
 		closed: make(chan struct{}),
-		buf1:   make([]byte, writeBufferSize),
-		buf2:   make([]byte, writeBufferSize),
 		ch:     make(chan writeRequest),
 	}
 	go w.loop()
@@ -202,25 +237,31 @@ func (w *goWriter) WriteContext(ctx context.Context, data []byte) (n int, err er
 		m, err := w.writeContext(ctx, data[n:])
 		n += m
 		if err != nil {
-			return n, err
+			return n, wrapError(OpWrite, "", int64(n), err)
 		}
 	}
 	return
 }
 
 func (w *goWriter) writeContext(ctx context.Context, data []byte) (n int, err error) {
-	buf := w.buf1
-	w.buf1, w.buf2 = w.buf2, w.buf1
+	if len(data) > writeBufferSize {
+		data = data[:writeBufferSize]
+	}
+	pool := BufferPoolFromContext(ctx)
+	buf := pool.Get(len(data))
 	ch := make(chan writeResponse, 1)
 	n = copy(buf, data)
 
+	// the buffer is owned by the loop once the request is sent.
 	req := writeRequest{
-		data: buf[:n],
+		data: buf,
+		pool: pool,
 		ch:   ch,
 	}
 	select {
 	case w.ch <- req:
 	case <-ctx.Done():
+		pool.Put(buf)
 		return n, ctx.Err()
 	}
 
@@ -248,6 +289,7 @@ func (w *goWriter) loop() {
 			return
 		}
 		n, err := w.w.Write(req.data)
+		req.pool.Put(req.data)
 
 		res := writeResponse{
 			n:   n,
@@ -262,7 +304,8 @@ type nopWriter struct {
 }
 
 func (w *nopWriter) WriteContext(ctx context.Context, data []byte) (int, error) {
-	return w.Write(data)
+	n, err := w.Write(data)
+	return n, wrapError(OpWrite, "", int64(n), err)
 }
 
 func (w *nopWriter) Close() error {
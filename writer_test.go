@@ -1,4 +1,4 @@
-package ctxio
+package ctxio_test
 
 import (
 	"bytes"
@@ -9,8 +9,31 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/shogo82148/ctxio"
+	"github.com/shogo82148/ctxio/ctxiotest"
 )
 
+// startedWriter closes started when the first Write is called,
+// so that tests can wait for the write to block.
+type startedWriter struct {
+	w       io.Writer
+	started chan struct{}
+}
+
+func newStartedWriter(w io.Writer) *startedWriter {
+	return &startedWriter{w: w, started: make(chan struct{})}
+}
+
+func (w *startedWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.started:
+	default:
+		close(w.started)
+	}
+	return w.w.Write(p)
+}
+
 func TestWatchWriter(t *testing.T) {
 	r, w, err := os.Pipe()
 	if err != nil {
@@ -35,7 +58,7 @@ func TestWatchWriter(t *testing.T) {
 		}
 	}()
 
-	ww := NewWriter(w)
+	ww := ctxio.NewWriter(w)
 
 	n, err := ww.WriteContext(context.Background(), data)
 	if err != nil {
@@ -71,7 +94,7 @@ func TestGoWriter(t *testing.T) {
 		}
 	}()
 
-	ww := NewWriter(w)
+	ww := ctxio.NewWriter(w)
 
 	n, err := ww.WriteContext(context.Background(), data)
 	if err != nil {
@@ -95,15 +118,22 @@ func TestGoWriter_Timeout(t *testing.T) {
 	defer w.Close()
 
 	data := bytes.Repeat([]byte("foobar01"), 1024*1024)
-	ww := NewWriter(w)
+	sw := newStartedWriter(w)
+	ww := ctxio.NewWriter(sw)
 
 	func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		clock := ctxiotest.NewFakeClock(epoch)
+		ctx, cancel := clock.WithTimeout(context.Background(), time.Second)
 		defer cancel()
+		go func() {
+			<-sw.started
+			clock.Advance(time.Second)
+		}()
 
+		// the first chunk is blocked in the pipe.
 		n, err := ww.WriteContext(ctx, data)
-		if n != writeBufferSize {
-			t.Errorf("want %d, got %d", writeBufferSize, n)
+		if n != ctxio.WriteBufferSize {
+			t.Errorf("want %d, got %d", ctxio.WriteBufferSize, n)
 		}
 		if !errors.Is(err, context.DeadlineExceeded) {
 			t.Errorf("want context.DeadlineExceeded, got %v", err)
@@ -116,10 +146,7 @@ func TestGoWriter_Timeout(t *testing.T) {
 	}()
 
 	func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
-
-		n, err := ww.WriteContext(ctx, data)
+		n, err := ww.WriteContext(context.Background(), data)
 		if n != len(data) {
 			t.Errorf("want %d, got %d", len(data), n)
 		}